@@ -0,0 +1,109 @@
+package netrie
+
+// Aggregate collapses sibling prefixes with the same name into their parent prefix and removes more specific
+// prefixes that have the same name as their covering prefix, lookup results stay the same.
+// Len and LenNodes are reduced, matched prefixes reported by LookupPrefix may become shorter.
+// Shared nodes of a minimized index are copied first, so that the index temporarily takes as much memory
+// as before Minimize, and Minimize should be called again after Aggregate.
+func (idx *CIDRIndex[S]) Aggregate() {
+	if len(idx.nodes) == 0 {
+		return
+	}
+
+	if idx.shared {
+		// Depth-first copy makes a separate node for every path.
+		idx.compact()
+		idx.shared = false
+	}
+
+	idx.aggregate(0, 0, -1)
+	idx.compact()
+}
+
+// aggregate processes subtree of the node at depth with the id of covering prefix,
+// returns true if the node has no id and no children and can be removed.
+func (idx *CIDRIndex[S]) aggregate(current int, depth int, covering S) bool {
+	node := &idx.nodes[current]
+
+	// More specific prefix with the same name is redundant.
+	if node.id != -1 && node.id == covering {
+		node.id, node.maskLen = -1, -1
+	}
+
+	effective := covering
+	if node.id != -1 {
+		effective = node.id
+	}
+
+	var (
+		ids     [2]S
+		uniform = true
+	)
+
+	for bit, child := range node.children {
+		ids[bit] = effective
+
+		if child == -1 {
+			continue
+		}
+
+		if idx.aggregate(int(child), depth+1, effective) {
+			node.children[bit] = -1
+
+			continue
+		}
+
+		c := idx.nodes[child]
+		if c.children != [2]int32{-1, -1} {
+			uniform = false
+		}
+
+		ids[bit] = c.id
+	}
+
+	// Siblings with the same name are replaced by parent prefix.
+	// IPv4 lookups stop at 32 bits, so deeper IPv6 prefixes are not collapsed into 32 bits.
+	if uniform && ids[0] == ids[1] && node.children != [2]int32{-1, -1} && depth != 32 {
+		node.children = [2]int32{-1, -1}
+
+		if ids[0] == covering {
+			node.id, node.maskLen = -1, -1
+		} else {
+			node.id, node.maskLen = ids[0], int8(depth)
+		}
+	}
+
+	return current != 0 && node.id == -1 && node.children == [2]int32{-1, -1}
+}
+
+// compact removes unreachable nodes keeping nodes in depth-first order and recounts prefixes,
+// shared nodes are copied for each parent.
+func (idx *CIDRIndex[S]) compact() {
+	nodes := make([]trieNode[S], 0, len(idx.nodes))
+	total := 0
+
+	var walk func(current int32) int32
+
+	walk = func(current int32) int32 {
+		i := int32(len(nodes))
+		node := idx.nodes[current]
+		nodes = append(nodes, node)
+
+		if node.id != -1 {
+			total++
+		}
+
+		for bit, child := range node.children {
+			if child != -1 {
+				nodes[i].children[bit] = walk(child)
+			}
+		}
+
+		return i
+	}
+
+	walk(0)
+
+	idx.nodes = nodes
+	idx.total = total
+}
@@ -0,0 +1,140 @@
+package netrie_test
+
+import (
+	"fmt"
+	"math/rand"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vearutop/netrie"
+)
+
+func TestCIDRIndex_Aggregate(t *testing.T) {
+	idx := netrie.NewCIDRIndex()
+
+	require.NoError(t, idx.AddCIDR("10.0.0.0/25", "a"))
+	require.NoError(t, idx.AddCIDR("10.0.0.128/26", "a"))
+	require.NoError(t, idx.AddCIDR("10.0.0.192/26", "a"))
+	require.NoError(t, idx.AddCIDR("10.1.0.0/16", "b"))
+	require.NoError(t, idx.AddCIDR("10.1.2.0/24", "b"))
+	require.NoError(t, idx.AddCIDR("10.1.3.0/24", "c"))
+	require.NoError(t, idx.AddCIDR("2001:db8::/34", "v6"))
+	require.NoError(t, idx.AddCIDR("2001:db8:4000::/34", "v6"))
+	require.NoError(t, idx.AddCIDR("2001:db8:8000::/33", "v6"))
+	require.NoError(t, idx.AddCIDR("::/33", "x")) // Shares path with 0.0.0.0/32.
+	require.NoError(t, idx.AddCIDR("0:0:8000::/33", "x"))
+
+	nodes := idx.LenNodes()
+
+	idx.Aggregate()
+
+	assert.Equal(t, 7, idx.Len())
+	assert.Less(t, idx.LenNodes(), nodes)
+
+	assert.Equal(t, "a", idx.Lookup("10.0.0.1"))
+	assert.Equal(t, "a", idx.Lookup("10.0.0.255"))
+	assert.Equal(t, "", idx.Lookup("10.0.1.0"))
+	assert.Equal(t, "b", idx.Lookup("10.1.2.3"))
+	assert.Equal(t, "c", idx.Lookup("10.1.3.3"))
+	assert.Equal(t, "v6", idx.Lookup("2001:db8:ffff::1"))
+	assert.Equal(t, "", idx.Lookup("2001:db9::1"))
+	assert.Equal(t, "x", idx.Lookup("::1"))
+	assert.Equal(t, "", idx.Lookup("0.0.0.1"))
+
+	_, p, err := idx.LookupPrefix(netip.MustParseAddr("10.0.0.200"))
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.0/24", p.String())
+
+	_, p, err = idx.LookupPrefix(netip.MustParseAddr("2001:db8::1"))
+	require.NoError(t, err)
+	assert.Equal(t, "2001:db8::/33", p.String()) // Not collapsed to 32 bits to keep IPv4 lookups.
+}
+
+func TestCIDRIndex_Aggregate_minimized(t *testing.T) {
+	build := func() *netrie.CIDRIndex[int16] {
+		idx := netrie.NewCIDRIndex()
+
+		for i := 0; i < 4; i++ {
+			require.NoError(t, idx.AddCIDR(fmt.Sprintf("10.%d.0.0/16", i), "a"))
+			require.NoError(t, idx.AddCIDR(fmt.Sprintf("10.%d.0.0/17", i), "a"))
+			require.NoError(t, idx.AddCIDR(fmt.Sprintf("10.%d.128.0/17", i), "a"))
+			require.NoError(t, idx.AddCIDR(fmt.Sprintf("10.%d.1.0/24", i), "b"))
+		}
+
+		return idx
+	}
+
+	expected := build()
+	expected.Aggregate()
+
+	idx := build()
+	idx.Minimize()
+	require.Less(t, idx.LenNodes(), expected.LenNodes())
+
+	// Shared nodes are copied before aggregation.
+	idx.Aggregate()
+	assert.Equal(t, expected.Len(), idx.Len())
+	assert.Equal(t, expected.LenNodes(), idx.LenNodes())
+	assert.Equal(t, 8, idx.Len())
+
+	for i := 0; i < 4; i++ {
+		assert.Equal(t, "a", idx.Lookup(fmt.Sprintf("10.%d.200.1", i)))
+		assert.Equal(t, "b", idx.Lookup(fmt.Sprintf("10.%d.1.1", i)))
+
+		addr := netip.MustParseAddr(fmt.Sprintf("10.%d.2.1", i))
+		_, want, err := expected.LookupPrefix(addr)
+		require.NoError(t, err)
+
+		_, p, err := idx.LookupPrefix(addr)
+		require.NoError(t, err)
+		assert.Equal(t, want, p)
+	}
+
+	// Index can be changed without affecting other paths, and minimized again.
+	require.NoError(t, idx.AddCIDR("10.0.2.0/24", "c"))
+	assert.Equal(t, "c", idx.Lookup("10.0.2.1"))
+	assert.Equal(t, "a", idx.Lookup("10.1.2.1"))
+
+	nodes := idx.LenNodes()
+	idx.Minimize()
+	assert.Less(t, idx.LenNodes(), nodes)
+	assert.Equal(t, "a", idx.Lookup("10.1.2.1"))
+}
+
+func TestCIDRIndex_Aggregate_random(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	idx := netrie.NewCIDRIndex()
+
+	for i := 0; i < 3000; i++ {
+		bits := 16 + rnd.Intn(17)
+		addr := netip.AddrFrom4([4]byte{10, 0, byte(rnd.Intn(256)), byte(rnd.Intn(256))})
+		p := netip.PrefixFrom(addr, bits).Masked()
+
+		require.NoError(t, idx.AddCIDR(p.String(), fmt.Sprintf("n%d", rnd.Intn(3))))
+	}
+
+	expected := make([]string, 1<<16)
+	for i := range expected {
+		expected[i] = idx.Lookup(fmt.Sprintf("10.0.%d.%d", i>>8, i&0xff))
+	}
+
+	total, nodes := idx.Len(), idx.LenNodes()
+
+	idx.Aggregate()
+
+	assert.Less(t, idx.Len(), total)
+	assert.Less(t, idx.LenNodes(), nodes)
+
+	for i := range expected {
+		ip := fmt.Sprintf("10.0.%d.%d", i>>8, i&0xff)
+		require.Equal(t, expected[i], idx.Lookup(ip), ip)
+	}
+
+	// Second pass has nothing to aggregate.
+	total, nodes = idx.Len(), idx.LenNodes()
+	idx.Aggregate()
+	assert.Equal(t, total, idx.Len())
+	assert.Equal(t, nodes, idx.LenNodes())
+}
@@ -5,6 +5,7 @@ import (
 	"bufio"
 	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -53,7 +54,7 @@ func (n *trieNode[S]) UnmarshalBinary(data []byte) error {
 		n.maskLen = int8(data[10])
 	case int32:
 		if len(data) != 13 {
-			return fmt.Errorf("insufficient data: got %d bytes, need 11", len(data))
+			return fmt.Errorf("insufficient data: got %d bytes, need 13", len(data))
 		}
 		n.children[0] = int32(binary.BigEndian.Uint32(data[0:4]))
 		n.children[1] = int32(binary.BigEndian.Uint32(data[4:8]))
@@ -66,14 +67,37 @@ func (n *trieNode[S]) UnmarshalBinary(data []byte) error {
 }
 
 // Save writes the CIDRIndex data to the given io.Writer, including metadata, nodes, and associated names.
-func (idx *CIDRIndex[S]) Save(w io.Writer) error {
+// Data is not compressed unless options set compression, see SaveOptions.
+func (idx *CIDRIndex[S]) Save(w io.Writer, opts ...func(o *SaveOptions)) error {
+	o := SaveOptions{Compression: CompressionNone}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return idx.saveWithOptions(w, o)
+}
+
+func (idx *CIDRIndex[S]) save(w io.Writer, o SaveOptions) error {
+	flag, err := namesFlag(o)
+	if err != nil {
+		return err
+	}
+
+	if err := idx.writeHeader(w, uint32(1)|flag); err != nil {
+		return err
+	}
+
+	return idx.writePayload(w, o)
+}
+
+// writeHeader writes header of given version and metadata.
+func (idx *CIDRIndex[S]) writeHeader(w io.Writer, ver uint32) error {
 	var s S
 
 	// Write header: version (int32), total (int32), nodesLen (int32), namesLen (int32)
 	header := make([]byte, 16)
 
-	ver := uint32(1) // Version 1.
-
 	// Switch bit 31 to indicate large namespace.
 	if _, ok := any(s).(int32); ok {
 		ver |= 1 << 31
@@ -103,11 +127,35 @@ func (idx *CIDRIndex[S]) Save(w io.Writer) error {
 		return fmt.Errorf("failed to write .Metadata: %w", err)
 	}
 
+	return nil
+}
+
+// writePayload writes nodes and names, front-coded names are sorted and node ids are remapped accordingly.
+func (idx *CIDRIndex[S]) writePayload(w io.Writer, o SaveOptions) error {
+	var (
+		s      S
+		names  = idx.names
+		newIDs []int32
+	)
+
+	if o.FrontCodedNames {
+		names, newIDs = sortedNames(idx.names)
+	}
+
+	remap := func(node trieNode[S]) trieNode[S] {
+		if newIDs != nil && node.id > 0 {
+			node.id = S(newIDs[node.id])
+		}
+
+		return node
+	}
+
 	switch any(s).(type) {
 	case int16:
 		// Write nodes
 		nodeBuf := make([]byte, 11) // Reusable buffer for each node
 		for i, node := range idx.nodes {
+			node = remap(node)
 			nodeData, err := node.MarshalBinary()
 			if err != nil {
 				return fmt.Errorf("failed to marshal node %d: %w", i, err)
@@ -121,6 +169,7 @@ func (idx *CIDRIndex[S]) Save(w io.Writer) error {
 		// Write nodes
 		nodeBuf := make([]byte, 13) // Reusable buffer for each node
 		for i, node := range idx.nodes {
+			node = remap(node)
 			nodeData, err := node.MarshalBinary()
 			if err != nil {
 				return fmt.Errorf("failed to marshal node %d: %w", i, err)
@@ -132,16 +181,22 @@ func (idx *CIDRIndex[S]) Save(w io.Writer) error {
 		}
 	}
 
-	// Write names
-	for i, name := range idx.names {
-		// Write string length (int32)
-		nameLenBuf := make([]byte, 4)
+	switch {
+	case o.FrontCodedNames:
+		return writeFrontCoded(w, names)
+	case o.NamesOffsets:
+		return writeIndexedNames(w, names)
+	}
+
+	// Write names of legacy format, each prefixed with length.
+	nameLenBuf := make([]byte, 4)
+	for i, name := range names {
 		binary.BigEndian.PutUint32(nameLenBuf, uint32(len(name)))
 		if _, err := w.Write(nameLenBuf); err != nil {
 			return fmt.Errorf("failed to write name %d length: %w", i, err)
 		}
-		// Write string bytes
-		if _, err := w.Write([]byte(name)); err != nil {
+
+		if _, err := io.WriteString(w, name); err != nil {
 			return fmt.Errorf("failed to write name %d: %w", i, err)
 		}
 	}
@@ -149,8 +204,22 @@ func (idx *CIDRIndex[S]) Save(w io.Writer) error {
 	return nil
 }
 
-// SaveToFile saves the CIDRIndex to a file.
-func (idx *CIDRIndex[S]) SaveToFile(filename string) error {
+// namesFlag returns header version flag of names section, legacy names have no flag.
+func namesFlag(o SaveOptions) (uint32, error) {
+	switch {
+	case o.FrontCodedNames && o.NamesOffsets:
+		return 0, errors.New("FrontCodedNames and NamesOffsets are mutually exclusive")
+	case o.FrontCodedNames:
+		return flagFrontCodedNames, nil
+	case o.NamesOffsets:
+		return flagNamesOffsets, nil
+	}
+
+	return 0, nil
+}
+
+// SaveToFile saves the CIDRIndex to a file, see Save.
+func (idx *CIDRIndex[S]) SaveToFile(filename string, opts ...func(o *SaveOptions)) error {
 	file, err := os.Create(filename)
 	if err != nil {
 		return fmt.Errorf("create file to save index: %w", err)
@@ -159,7 +228,7 @@ func (idx *CIDRIndex[S]) SaveToFile(filename string) error {
 
 	w := bufio.NewWriter(file)
 
-	if err := idx.Save(w); err != nil {
+	if err := idx.Save(w, opts...); err != nil {
 		return fmt.Errorf("save file: %w", err)
 	}
 
@@ -180,6 +249,12 @@ type hd struct {
 
 	hasLargeNamespace bool
 	nodeSize          int64
+
+	// compression of blocks in version 2 (seekable compressed) format.
+	compression Compression
+
+	frontCodedNames bool
+	namesOffsets    bool
 }
 
 func (h *hd) UnmarshalBinary(data []byte) error {
@@ -196,13 +271,33 @@ func (h *hd) UnmarshalBinary(data []byte) error {
 	h.hasLargeNamespace = (h.ver & (1 << 31)) != 0
 	h.ver &^= 1 << 31 // Remove large namespace flag.
 
+	h.frontCodedNames = (h.ver & flagFrontCodedNames) != 0
+	h.namesOffsets = (h.ver & flagNamesOffsets) != 0
+	h.ver &^= flagFrontCodedNames | flagNamesOffsets
+
+	if h.frontCodedNames && h.namesOffsets {
+		return errors.New("conflicting names flags")
+	}
+
+	if flags := h.ver >> 24; flags != 0 {
+		return fmt.Errorf("unsupported flags: %#x", flags<<24)
+	}
+
+	h.compression = Compression(h.ver >> 16)
+	h.ver &= 0xffff // Remove compression flags.
+
 	if h.hasLargeNamespace {
 		h.nodeSize = 13
 	} else {
 		h.nodeSize = 11
 	}
 
-	if h.ver != 1 {
+	switch {
+	case h.ver == 1 && h.compression == CompressionNone:
+	case h.ver == 2 && (h.compression == CompressionGzip || h.compression == CompressionZstd):
+	case h.ver == 2:
+		return fmt.Errorf("unsupported compression: %d", h.compression)
+	default:
 		return fmt.Errorf("invalid version: %d", h.ver)
 	}
 
@@ -211,7 +306,17 @@ func (h *hd) UnmarshalBinary(data []byte) error {
 
 // Load initializes and returns an IPLookuper by reading and parsing data from the provided io.Reader.
 // Returns an error if the input data is invalid or the operation fails.
-func Load(r io.Reader) (IPLookuper, error) {
+// Gzip or zstd compressed streams and seekable compressed format are detected automatically.
+// Options.Strict enables validation of the loaded index, limits of Options are applied, other options are ignored.
+func Load(r io.Reader, opts ...func(o *Options)) (IPLookuper, error) {
+	o := newOptions(opts)
+
+	r, closeStream, err := decompressStream(r)
+	if err != nil {
+		return nil, err
+	}
+	defer closeStream()
+
 	// Read header: version (uint32), total (uint32), nodesLen (uint32), namesLen (uint32), metadataLen (uint32)
 	header := make([]byte, 20)
 	if _, err := io.ReadFull(r, header); err != nil {
@@ -220,13 +325,20 @@ func Load(r io.Reader) (IPLookuper, error) {
 
 	h := hd{}
 	if err := h.UnmarshalBinary(header); err != nil {
+		if o.Strict {
+			return nil, &ValidationError{Section: "header", Index: -1, Err: err}
+		}
+
 		return nil, fmt.Errorf("unmarshal header: %w", err)
 	}
 
-	if h.metadataLen > 0 {
-		metadataBuf := make([]byte, h.metadataLen)
+	if err := o.checkHeader(h); err != nil {
+		return nil, err
+	}
 
-		if _, err := io.ReadFull(r, metadataBuf); err != nil {
+	if h.metadataLen > 0 {
+		metadataBuf, err := readFull(r, int64(h.metadataLen))
+		if err != nil {
 			return nil, fmt.Errorf("read metadata: %w", err)
 		}
 
@@ -235,10 +347,16 @@ func Load(r io.Reader) (IPLookuper, error) {
 		}
 	}
 
+	if h.ver == 2 {
+		if r, err = newBlockStreamReader(r, h.compression); err != nil {
+			return nil, err
+		}
+	}
+
 	if h.hasLargeNamespace {
 		idx := NewCIDRLargeIndex()
 
-		if err := idx.load(h, r); err != nil {
+		if err := idx.load(h, r, o); err != nil {
 			return nil, err
 		}
 
@@ -247,20 +365,39 @@ func Load(r io.Reader) (IPLookuper, error) {
 
 	idx := NewCIDRIndex()
 
-	if err := idx.load(h, r); err != nil {
+	if err := idx.load(h, r, o); err != nil {
 		return nil, err
 	}
 
 	return idx, nil
 }
 
-func (idx *CIDRIndex[S]) load(h hd, r io.Reader) error {
+func (idx *CIDRIndex[S]) load(h hd, r io.Reader, o Options) error {
+	if err := idx.loadData(h, r, o); err != nil {
+		return err
+	}
+
+	if !o.Strict {
+		return idx.checkNodes()
+	}
+
+	if n, _ := r.Read(make([]byte, 1)); n > 0 {
+		return invalid("names", -1, "unexpected data after names")
+	}
+
+	return idx.validate()
+}
+
+// loadChunk is a maximal number of items to allocate before they are read, so that invalid header
+// does not cause a huge allocation.
+const loadChunk = 1 << 16
+
+func (idx *CIDRIndex[S]) loadData(h hd, r io.Reader, o Options) error {
 	idx.meta = h.meta
 
 	// Initialize CIDRIndex fields
 	idx.total = int(h.total)
-	idx.nodes = make([]trieNode[S], h.nodesLen)
-	idx.names = make([]string, h.namesLen)
+	idx.nodes = make([]trieNode[S], 0, min(h.nodesLen, loadChunk))
 
 	// Read nodes
 	nodeBuf := make([]byte, h.nodeSize)
@@ -268,35 +405,76 @@ func (idx *CIDRIndex[S]) load(h hd, r io.Reader) error {
 		if _, err := io.ReadFull(r, nodeBuf); err != nil {
 			return fmt.Errorf("read node %d: %w", i, err)
 		}
-		if err := idx.nodes[i].UnmarshalBinary(nodeBuf); err != nil {
+
+		var node trieNode[S]
+		if err := node.UnmarshalBinary(nodeBuf); err != nil {
 			return fmt.Errorf("unmarshal node %d: %w", i, err)
 		}
+
+		idx.nodes = append(idx.nodes, node)
+	}
+
+	idx.shared = hasSharedNodes(idx.nodes)
+
+	if h.frontCodedNames {
+		return idx.loadFrontCoded(h, r, o)
 	}
 
-	// Read names
+	if h.namesOffsets {
+		return idx.loadIndexedNames(h, r, o)
+	}
+
+	// Read names of legacy format, each prefixed with length.
+	idx.names = make([]string, 0, min(h.namesLen, loadChunk))
+
 	for i := 0; i < int(h.namesLen); i++ {
 		// Read string length (int32)
 		nameLenBuf := make([]byte, 4)
 		if _, err := io.ReadFull(r, nameLenBuf); err != nil {
 			return fmt.Errorf("read name %d length: %w", i, err)
 		}
-		nameLen := int(binary.BigEndian.Uint32(nameLenBuf))
+		nameLen := int64(binary.BigEndian.Uint32(nameLenBuf))
+
+		if err := o.checkNameLen(i, nameLen); err != nil {
+			return err
+		}
 
 		// Read string bytes
-		nameBuf := make([]byte, nameLen)
-		if _, err := io.ReadFull(r, nameBuf); err != nil {
+		nameBuf, err := readFull(r, nameLen)
+		if err != nil {
 			return fmt.Errorf("read name %d len %d: %w", i, nameLen, err)
 		}
 		name := string(nameBuf)
-		idx.names[i] = name
-		idx.idByName[name] = S(i)
+		idx.names = append(idx.names, name)
+		idx.idByName[name] = S(i + 1)
+	}
+
+	return nil
+}
+
+// checkNodes checks that children and name ids of loaded nodes are in range, so that lookups do not panic.
+func (idx *CIDRIndex[S]) checkNodes() error {
+	if len(idx.nodes) == 0 {
+		return invalid("nodes", -1, "no root node")
+	}
+
+	for i, node := range idx.nodes {
+		for _, c := range node.children {
+			if c < -1 || int(c) >= len(idx.nodes) {
+				return invalid("nodes", int64(i), "child %d is out of range [0, %d)", c, len(idx.nodes))
+			}
+		}
+
+		if node.id != -1 && (node.id < 1 || int(node.id) > len(idx.names)) {
+			return invalid("nodes", int64(i), "name id %d is out of range [1, %d]", node.id, len(idx.names))
+		}
 	}
 
 	return nil
 }
 
 // LoadFromFile loads the entire CIDRIndex from a file to memory.
-func LoadFromFile(filename string) (IPLookuper, error) {
+func LoadFromFile(filename string, opts ...func(o *Options)) (IPLookuper, error) {
 	file, err := os.Open(filename)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open file: %w", err)
@@ -305,5 +483,5 @@ func LoadFromFile(filename string) (IPLookuper, error) {
 
 	r := bufio.NewReader(file)
 
-	return Load(r)
+	return Load(r, opts...)
 }
@@ -0,0 +1,257 @@
+package netrie
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+// ErrConflict is returned by FailOnConflict policy when a prefix is added with a different name.
+var ErrConflict = errors.New("conflicting names")
+
+// ConflictPolicy resolves a name for a prefix that already has a different name.
+type ConflictPolicy func(prefix netip.Prefix, existing, added string) (string, error)
+
+// KeepFirst is a ConflictPolicy that keeps the name that was added first.
+func KeepFirst(_ netip.Prefix, existing, _ string) (string, error) {
+	return existing, nil
+}
+
+// KeepLast is a ConflictPolicy that replaces the name with the one added last, same as CIDRIndex.AddNet.
+func KeepLast(_ netip.Prefix, _, added string) (string, error) {
+	return added, nil
+}
+
+// FailOnConflict is a ConflictPolicy that rejects a different name with ErrConflict and keeps the existing one.
+func FailOnConflict(prefix netip.Prefix, existing, added string) (string, error) {
+	return "", fmt.Errorf("%w for %s: %q and %q", ErrConflict, prefix, existing, added)
+}
+
+// ConcatNames returns a ConflictPolicy that joins sorted unique names with the separator.
+// Existing name is split by the separator, so that more than two names can be accumulated.
+func ConcatNames(sep string) ConflictPolicy {
+	return func(_ netip.Prefix, existing, added string) (string, error) {
+		names := append(strings.Split(existing, sep), added)
+		slices.Sort(names)
+
+		return strings.Join(slices.Compact(names), sep), nil
+	}
+}
+
+// Conflict describes a prefix that was added with a different name.
+type Conflict struct {
+	Prefix   netip.Prefix
+	Existing string
+	Added    string
+	Result   string // Name stored for the prefix.
+	Err      error  // Error of conflict policy, existing name is kept.
+}
+
+// BuilderOptions configures Builder.
+type BuilderOptions struct {
+	// Conflict resolves names of duplicate prefixes, default KeepLast.
+	Conflict ConflictPolicy
+}
+
+// Builder adds networks to CIDRIndex resolving duplicate prefixes with a conflict policy
+// and keeping a report of conflicts.
+//
+// IPv4 and IPv6 prefixes share the trie of the index, so an IPv6 prefix of up to 32 bits has the same node
+// as an IPv4 prefix with the same leading bits. Such prefixes of different families are not conflicts,
+// the name of the last one is stored as in CIDRIndex.
+type Builder[S int16 | int32] struct {
+	idx       *CIDRIndex[S]
+	opts      BuilderOptions
+	conflicts []Conflict
+	err       error
+
+	// sharedIPv6 has IPv6 prefixes that share nodes with IPv4 prefixes,
+	// value is true if the node has the name of the IPv6 prefix.
+	sharedIPv6 map[netip.Prefix]bool
+}
+
+// NewBuilder creates a Builder of CIDRIndex for up to 2^16 networks.
+func NewBuilder(opts ...func(o *BuilderOptions)) *Builder[int16] {
+	return newBuilder(NewCIDRIndex(), opts...)
+}
+
+// NewLargeBuilder creates a Builder of CIDRIndex for up to 2^32 networks.
+func NewLargeBuilder(opts ...func(o *BuilderOptions)) *Builder[int32] {
+	return newBuilder(NewCIDRLargeIndex(), opts...)
+}
+
+func newBuilder[S int16 | int32](idx *CIDRIndex[S], opts ...func(o *BuilderOptions)) *Builder[S] {
+	b := &Builder[S]{idx: idx}
+	b.opts.Conflict = KeepLast
+
+	for _, opt := range opts {
+		opt(&b.opts)
+	}
+
+	return b
+}
+
+// Metadata returns a reference to the Metadata of the index.
+func (b *Builder[S]) Metadata() *Metadata {
+	return b.idx.Metadata()
+}
+
+// Index returns the built index.
+func (b *Builder[S]) Index() *CIDRIndex[S] {
+	return b.idx
+}
+
+// Conflicts returns all conflicts seen so far in order of insertion.
+func (b *Builder[S]) Conflicts() []Conflict {
+	return b.conflicts
+}
+
+// Err returns the first error of conflict policy that occurred in AddNet.
+func (b *Builder[S]) Err() error {
+	return b.err
+}
+
+// AddNet inserts a CIDR block into the index, name of existing prefix is resolved with conflict policy.
+// Conflict policy error is available with Err.
+func (b *Builder[S]) AddNet(ipNet *net.IPNet, name string) {
+	if err := b.add(ipNet, name); err != nil && b.err == nil {
+		b.err = err
+	}
+}
+
+// AddCIDR adds a CIDR with an associated name to the index.
+// Returns error if CIDR is invalid, conflict policy fails, or ErrTooManyNames if the name does not fit in the namespace.
+func (b *Builder[S]) AddCIDR(cidr string, name string) error {
+	p, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return fmt.Errorf("invalid CIDR (%s): %v", name, cidr)
+	}
+
+	return b.addPrefix(p, name)
+}
+
+// AddRange adds an inclusive range of addresses decomposed into a minimal set of CIDRs with an associated name.
+// Returns error if range is invalid or conflict policy fails.
+func (b *Builder[S]) AddRange(start, end netip.Addr, name string) error {
+	nets, err := RangeNets(start, end)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	for _, n := range nets {
+		if err := b.add(n, name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// otherFamily records the family of prefix p that is stored in a node shared by IPv4 and IPv6 prefixes,
+// and reports if the node had the name of a prefix of the other family.
+func (b *Builder[S]) otherFamily(p netip.Prefix) bool {
+	if p.Bits() > 32 {
+		return false
+	}
+
+	key := p
+	if p.Addr().Is4() {
+		var ip [16]byte
+
+		v4 := p.Addr().As4()
+		copy(ip[:], v4[:])
+
+		key = netip.PrefixFrom(netip.AddrFrom16(ip), p.Bits())
+	}
+
+	isIPv6, seen := b.sharedIPv6[key]
+
+	if p.Addr().Is4() {
+		if seen {
+			b.sharedIPv6[key] = false
+		}
+
+		return isIPv6
+	}
+
+	if b.sharedIPv6 == nil {
+		b.sharedIPv6 = make(map[netip.Prefix]bool)
+	}
+
+	b.sharedIPv6[key] = true
+
+	// Node without recorded IPv6 prefix has the name of IPv4 prefix.
+	return !isIPv6
+}
+
+func (b *Builder[S]) add(ipNet *net.IPNet, name string) error {
+	p, err := netPrefix(ipNet, name)
+	if err != nil {
+		return err
+	}
+
+	return b.addPrefix(p, name)
+}
+
+func (b *Builder[S]) addPrefix(p netip.Prefix, name string) error {
+	p, err := checkPrefix(p, name)
+	if err != nil {
+		return err
+	}
+
+	idx := b.idx
+	current, maskLen := idx.prefixNode(p), p.Bits()
+	node := &idx.nodes[current]
+
+	if node.id == -1 {
+		id, err := idx.nameID(name)
+		if err != nil {
+			return err
+		}
+
+		node.id = id
+		node.maskLen = int8(maskLen)
+		idx.total++
+
+		b.otherFamily(p)
+
+		return nil
+	}
+
+	if b.otherFamily(p) {
+		id, err := idx.nameID(name)
+		if err != nil {
+			return err
+		}
+
+		node.id = id
+
+		return nil
+	}
+
+	existing := idx.names[node.id-1]
+	if existing == name {
+		return nil
+	}
+
+	c := Conflict{Prefix: p, Existing: existing, Added: name}
+
+	c.Result, c.Err = b.opts.Conflict(c.Prefix, existing, name)
+	if c.Err != nil {
+		c.Result = existing
+	} else if c.Result != existing {
+		id, err := idx.nameID(c.Result)
+		if err != nil {
+			return err
+		}
+
+		node.id = id
+	}
+
+	b.conflicts = append(b.conflicts, c)
+
+	return c.Err
+}
@@ -0,0 +1,131 @@
+package netrie_test
+
+import (
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vearutop/netrie"
+)
+
+func TestBuilder_conflicts(t *testing.T) {
+	add := func(t *testing.T, b *netrie.Builder[int16]) {
+		t.Helper()
+
+		require.NoError(t, b.AddCIDR("10.0.0.0/8", "a"))
+		require.NoError(t, b.AddCIDR("10.1.0.0/16", "b"))
+		require.NoError(t, b.AddCIDR("10.0.0.0/8", "a")) // Same name is not a conflict.
+		b.AddNet(mustNet(t, "10.0.0.0/8"), "c")
+		b.AddNet(mustNet(t, "10.0.0.0/8"), "b")
+		b.AddNet(mustNet(t, "2001:db8::/32"), "v6")
+		b.AddNet(mustNet(t, "2001:db8::/32"), "v6-dup")
+	}
+
+	for _, tc := range []struct {
+		name      string
+		policy    netrie.ConflictPolicy
+		v4, v6    string
+		results   []string
+		errPrefix string
+	}{
+		{name: "default", v4: "b", v6: "v6-dup", results: []string{"c", "b", "v6-dup"}},
+		{name: "first", policy: netrie.KeepFirst, v4: "a", v6: "v6", results: []string{"a", "a", "v6"}},
+		{name: "last", policy: netrie.KeepLast, v4: "b", v6: "v6-dup", results: []string{"c", "b", "v6-dup"}},
+		{
+			name: "concat", policy: netrie.ConcatNames("|"), v4: "a|b|c", v6: "v6|v6-dup",
+			results: []string{"a|c", "a|b|c", "v6|v6-dup"},
+		},
+		{
+			name: "custom",
+			policy: func(prefix netip.Prefix, existing, added string) (string, error) {
+				return strings.ToUpper(added) + "@" + prefix.String(), nil
+			},
+			v4: "B@10.0.0.0/8", v6: "V6-DUP@2001:db8::/32",
+			results: []string{"C@10.0.0.0/8", "B@10.0.0.0/8", "V6-DUP@2001:db8::/32"},
+		},
+		{
+			name: "error", policy: netrie.FailOnConflict, v4: "a", v6: "v6", results: []string{"a", "a", "v6"},
+			errPrefix: `conflicting names for 10.0.0.0/8: "a" and "c"`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			b := netrie.NewBuilder(func(o *netrie.BuilderOptions) {
+				if tc.policy != nil {
+					o.Conflict = tc.policy
+				}
+			})
+
+			add(t, b)
+
+			idx := b.Index()
+			assert.Equal(t, 3, idx.Len())
+			assert.Equal(t, tc.v4, idx.Lookup("10.2.3.4"))
+			assert.Equal(t, "b", idx.Lookup("10.1.3.4"))
+			assert.Equal(t, tc.v6, idx.Lookup("2001:db8::1"))
+
+			conflicts := b.Conflicts()
+			require.Len(t, conflicts, 3)
+
+			assert.Equal(t, "10.0.0.0/8", conflicts[0].Prefix.String())
+			assert.Equal(t, "a", conflicts[0].Existing)
+			assert.Equal(t, "c", conflicts[0].Added)
+			assert.Equal(t, "2001:db8::/32", conflicts[2].Prefix.String())
+
+			for i, c := range conflicts {
+				assert.Equal(t, tc.results[i], c.Result, i)
+			}
+
+			if tc.errPrefix == "" {
+				assert.NoError(t, b.Err())
+
+				return
+			}
+
+			require.ErrorIs(t, b.Err(), netrie.ErrConflict)
+			assert.EqualError(t, b.Err(), tc.errPrefix)
+			require.ErrorIs(t, conflicts[2].Err, netrie.ErrConflict)
+			assert.ErrorIs(t, b.AddCIDR("2001:db8::/32", "v6-other"), netrie.ErrConflict)
+		})
+	}
+}
+
+func TestBuilder_conflicts_family(t *testing.T) {
+	b := netrie.NewBuilder(func(o *netrie.BuilderOptions) { o.Conflict = netrie.FailOnConflict })
+
+	// Prefixes of different families with the same node are not conflicts, last name is stored.
+	require.NoError(t, b.AddCIDR("10.0.0.0/8", "v4"))
+	require.NoError(t, b.AddCIDR("a00::/8", "v6"))
+	assert.Equal(t, "v6", b.Index().Lookup("a01::1"))
+
+	require.NoError(t, b.AddCIDR("10.0.0.0/8", "v4"))
+	assert.Equal(t, "v4", b.Index().Lookup("10.1.2.3"))
+	assert.Empty(t, b.Conflicts())
+
+	// Same family is compared with the name of its last prefix.
+	require.ErrorIs(t, b.AddCIDR("10.0.0.0/8", "other"), netrie.ErrConflict)
+	require.NoError(t, b.AddCIDR("a00::/8", "v6"))
+	require.ErrorIs(t, b.AddCIDR("a00::/8", "other"), netrie.ErrConflict)
+
+	// Longer IPv6 prefixes do not share nodes with IPv4 prefixes.
+	require.NoError(t, b.AddCIDR("a00::/40", "v6"))
+	require.ErrorIs(t, b.AddCIDR("a00::/40", "other"), netrie.ErrConflict)
+
+	conflicts := b.Conflicts()
+	require.Len(t, conflicts, 3)
+	assert.Equal(t, "10.0.0.0/8", conflicts[0].Prefix.String())
+	assert.Equal(t, "a00::/8", conflicts[1].Prefix.String())
+	assert.Equal(t, "v6", conflicts[1].Existing)
+	assert.Equal(t, "a00::/40", conflicts[2].Prefix.String())
+}
+
+func mustNet(t *testing.T, cidr string) *net.IPNet {
+	t.Helper()
+
+	_, n, err := net.ParseCIDR(cidr)
+	require.NoError(t, err)
+
+	return n
+}
@@ -0,0 +1,358 @@
+package netrie
+
+import (
+	"hash/maphash"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// CacheOptions configures Cached.
+type CacheOptions struct {
+	Shards int // Number of independently locked shards, default 16.
+
+	// Subnets enables caching of a whole /24 (IPv4) or /48 (IPv6) subnet with a single entry,
+	// when the underlying IPLookuper implements UniformLookuper and reports that all addresses
+	// of the subnet resolve to the same name.
+	Subnets bool
+}
+
+// CachedLookuper is an IPLookuper decorator that caches lookup results by address.
+type CachedLookuper struct {
+	IPLookuper
+
+	uniform UniformLookuper
+	seed    maphash.Seed
+	shards  []cacheShard
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// Cached wraps IPLookuper with a concurrent sharded CLOCK cache of up to size entries.
+func Cached(l IPLookuper, size int, opts ...func(o *CacheOptions)) *CachedLookuper {
+	o := CacheOptions{Shards: 16}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.Shards <= 0 {
+		o.Shards = 1
+	}
+
+	if size < o.Shards {
+		size = o.Shards
+	}
+
+	c := &CachedLookuper{
+		IPLookuper: l,
+		seed:       maphash.MakeSeed(),
+		shards:     make([]cacheShard, o.Shards),
+	}
+
+	if o.Subnets {
+		c.uniform, _ = l.(UniformLookuper)
+	}
+
+	for i := range c.shards {
+		c.shards[i].init(size / o.Shards)
+	}
+
+	return c
+}
+
+// cacheKey is an address or a subnet address.
+type cacheKey struct {
+	addr   netip.Addr
+	subnet bool
+}
+
+func (c *CachedLookuper) shard(k cacheKey) *cacheShard {
+	return &c.shards[maphash.Comparable(c.seed, k)%uint64(len(c.shards))]
+}
+
+// subnetBits returns the mask length of cached subnets for addr.
+func subnetBits(addr netip.Addr) int {
+	if addr.Is4() {
+		return 24
+	}
+
+	return 48
+}
+
+func subnetKey(addr netip.Addr) cacheKey {
+	p, _ := addr.Prefix(subnetBits(addr))
+
+	return cacheKey{addr: p.Addr(), subnet: true}
+}
+
+func (c *CachedLookuper) get(addr netip.Addr) (string, bool) {
+	if name, ok := c.shard(cacheKey{addr: addr}).get(cacheKey{addr: addr}); ok {
+		return name, true
+	}
+
+	if c.uniform != nil {
+		k := subnetKey(addr)
+
+		return c.shard(k).get(k)
+	}
+
+	return "", false
+}
+
+func (c *CachedLookuper) lookupAddr(addr netip.Addr) (string, error) {
+	addr = addr.Unmap()
+
+	if name, ok := c.get(addr); ok {
+		c.hits.Add(1)
+
+		return name, nil
+	}
+
+	c.misses.Add(1)
+
+	if c.uniform == nil {
+		name, err := c.IPLookuper.SafeLookupIP(addr.AsSlice())
+		if err != nil {
+			return "", err
+		}
+
+		k := cacheKey{addr: addr}
+		c.shard(k).put(k, name)
+
+		return name, nil
+	}
+
+	name, uniform, err := c.uniform.LookupUniform(addr)
+	if err != nil {
+		return "", err
+	}
+
+	c.put(addr, name, uniform)
+
+	return name, nil
+}
+
+// put stores name for the subnet of addr if whole subnet is within uniform prefix, or for addr otherwise.
+func (c *CachedLookuper) put(addr netip.Addr, name string, uniform netip.Prefix) {
+	k := cacheKey{addr: addr}
+
+	if uniform.IsValid() && uniform.Bits() <= subnetBits(addr) {
+		k = subnetKey(addr)
+	}
+
+	c.shard(k).put(k, name)
+}
+
+// SafeLookupIP finds the name of the CIDR that contains the given IP, using cached result if available.
+func (c *CachedLookuper) SafeLookupIP(ip net.IP) (string, error) {
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return "", nil
+	}
+
+	return c.lookupAddr(addr)
+}
+
+// LookupIP finds the name of the CIDR that contains the given IP, using cached result if available.
+// Returns "error: ..." if lookup fails.
+func (c *CachedLookuper) LookupIP(ip net.IP) string {
+	name, err := c.SafeLookupIP(ip)
+	if err != nil {
+		return "error: " + err.Error()
+	}
+
+	return name
+}
+
+// Lookup finds the name of the CIDR that contains the given IP string, using cached result if available.
+// Returns "" if no matching CIDR is found or IP is invalid.
+func (c *CachedLookuper) Lookup(ipStr string) string {
+	ip := net.ParseIP(ipStr)
+	if ip == nil {
+		return "" // Invalid IP address.
+	}
+
+	return c.LookupIP(ip)
+}
+
+// LookupBatch finds names for all ips and stores them in out, out must be at least as long as ips.
+// Cache misses are resolved with a single batch lookup in the underlying IPLookuper.
+func (c *CachedLookuper) LookupBatch(ips []netip.Addr, out []string) {
+	var (
+		missed []netip.Addr
+		pos    []int
+	)
+
+	_ = out[:len(ips)] // Fail early if out is too short.
+
+	for i, addr := range ips {
+		if !addr.IsValid() {
+			out[i] = ""
+
+			continue
+		}
+
+		addr = addr.Unmap()
+
+		if name, ok := c.get(addr); ok {
+			c.hits.Add(1)
+
+			out[i] = name
+
+			continue
+		}
+
+		c.misses.Add(1)
+
+		if c.uniform != nil {
+			name, uniform, err := c.uniform.LookupUniform(addr)
+			if err != nil {
+				out[i] = "error: " + err.Error()
+
+				continue
+			}
+
+			c.put(addr, name, uniform)
+			out[i] = name
+
+			continue
+		}
+
+		missed = append(missed, addr)
+		pos = append(pos, i)
+	}
+
+	if len(missed) == 0 {
+		return
+	}
+
+	names := make([]string, len(missed))
+	LookupBatch(c.IPLookuper, missed, names)
+
+	for j, name := range names {
+		out[pos[j]] = name
+
+		if strings.HasPrefix(name, "error: ") {
+			continue
+		}
+
+		k := cacheKey{addr: missed[j]}
+		c.shard(k).put(k, name)
+	}
+}
+
+// CacheStats is a snapshot of cache counters.
+type CacheStats struct {
+	Hits    uint64
+	Misses  uint64
+	Entries int
+}
+
+// HitRatio returns the share of lookups served from cache.
+func (s CacheStats) HitRatio() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// Stats returns a snapshot of cache counters.
+func (c *CachedLookuper) Stats() CacheStats {
+	s := CacheStats{
+		Hits:   c.hits.Load(),
+		Misses: c.misses.Load(),
+	}
+
+	for i := range c.shards {
+		s.Entries += c.shards[i].len()
+	}
+
+	return s
+}
+
+// cacheShard is a fixed capacity CLOCK cache.
+type cacheShard struct {
+	mu      sync.RWMutex
+	byKey   map[cacheKey]int
+	entries []cacheEntry
+	hand    int
+}
+
+type cacheEntry struct {
+	key  cacheKey
+	name string
+	ref  atomic.Bool
+}
+
+func (s *cacheShard) init(size int) {
+	if size < 1 {
+		size = 1
+	}
+
+	s.byKey = make(map[cacheKey]int, size)
+	s.entries = make([]cacheEntry, 0, size)
+}
+
+func (s *cacheShard) len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.entries)
+}
+
+func (s *cacheShard) get(k cacheKey) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i, ok := s.byKey[k]
+	if !ok {
+		return "", false
+	}
+
+	e := &s.entries[i]
+	e.ref.Store(true)
+
+	return e.name, true
+}
+
+func (s *cacheShard) put(k cacheKey, name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if i, ok := s.byKey[k]; ok {
+		s.entries[i].name = name
+
+		return
+	}
+
+	if len(s.entries) < cap(s.entries) {
+		s.byKey[k] = len(s.entries)
+		s.entries = append(s.entries, cacheEntry{key: k, name: name})
+
+		return
+	}
+
+	// Advance clock hand to the first entry that was not referenced since last pass.
+	for {
+		e := &s.entries[s.hand]
+		if !e.ref.Swap(false) {
+			break
+		}
+
+		s.hand = (s.hand + 1) % len(s.entries)
+	}
+
+	e := &s.entries[s.hand]
+	delete(s.byKey, e.key)
+
+	e.key = k
+	e.name = name
+	s.byKey[k] = s.hand
+
+	s.hand = (s.hand + 1) % len(s.entries)
+}
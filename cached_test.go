@@ -0,0 +1,133 @@
+package netrie_test
+
+import (
+	"net"
+	"net/netip"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vearutop/netrie"
+)
+
+func TestCached(t *testing.T) {
+	tr := netrie.NewCIDRIndex()
+	require.NoError(t, tr.AddCIDR("10.0.0.0/8", "net1"))
+	require.NoError(t, tr.AddCIDR("10.1.2.128/25", "net2"))
+	require.NoError(t, tr.AddCIDR("2001:db8::/32", "net3"))
+
+	for _, subnets := range []bool{false, true} {
+		c := netrie.Cached(tr, 100, func(o *netrie.CacheOptions) {
+			o.Shards = 2
+			o.Subnets = subnets
+		})
+
+		for i := 0; i < 3; i++ {
+			assert.Equal(t, "net1", c.Lookup("10.1.2.3"))
+			assert.Equal(t, "net2", c.Lookup("10.1.2.200"))
+			assert.Equal(t, "net1", c.Lookup("10.1.3.3"))
+			assert.Equal(t, "net1", c.Lookup("10.1.3.4"))
+			assert.Equal(t, "net3", c.Lookup("2001:db8::1"))
+			assert.Equal(t, "", c.Lookup("8.8.8.8"))
+			assert.Equal(t, "", c.Lookup("invalid"))
+		}
+
+		st := c.Stats()
+		assert.Equal(t, uint64(18), st.Hits+st.Misses)
+
+		if subnets {
+			// 10.1.3.0/24 is uniform, both addresses share an entry.
+			assert.Equal(t, uint64(5), st.Misses)
+			assert.Equal(t, 5, st.Entries)
+		} else {
+			assert.Equal(t, uint64(6), st.Misses)
+			assert.Equal(t, 6, st.Entries)
+		}
+
+		out := make([]string, 4)
+		c.LookupBatch([]netip.Addr{
+			netip.MustParseAddr("10.1.2.200"), {}, netip.MustParseAddr("::ffff:10.1.2.3"), netip.MustParseAddr("10.200.0.1"),
+		}, out)
+		assert.Equal(t, []string{"net2", "", "net1", "net1"}, out)
+	}
+}
+
+func TestCached_eviction(t *testing.T) {
+	tr := netrie.NewCIDRIndex()
+	require.NoError(t, tr.AddCIDR("10.0.0.0/8", "net1"))
+
+	c := netrie.Cached(tr, 2, func(o *netrie.CacheOptions) {
+		o.Shards = 1
+	})
+
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, "net1", c.LookupIP(net.IPv4(10, 0, 0, byte(i))))
+		assert.Equal(t, "net1", c.Lookup("10.255.255.255"))
+	}
+
+	st := c.Stats()
+	assert.Equal(t, 2, st.Entries)
+	assert.Equal(t, uint64(20), st.Hits+st.Misses)
+}
+
+func TestCached_concurrent(t *testing.T) {
+	trf, err := netrie.OpenFile("testdata/cities.bin")
+	require.NoError(t, err)
+
+	c := netrie.Cached(trf, 100, func(o *netrie.CacheOptions) {
+		o.Subnets = true
+	})
+	defer c.Close()
+
+	wg := sync.WaitGroup{}
+	wg.Add(50)
+
+	for i := 0; i < 50; i++ {
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 20; j++ {
+				assert.Equal(t, "GB:Boxford", c.Lookup("2.125.160.217"))
+				assert.Equal(t, "GB:London", c.Lookup("81.2.69.145"))
+				assert.Equal(t, "US:San Diego", c.Lookup("2001:480:10::1"))
+				assert.Equal(t, "", c.Lookup("143.198.196.44"))
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	assert.Greater(t, c.Stats().HitRatio(), 0.9)
+}
+
+func BenchmarkCached(b *testing.B) {
+	trf, err := netrie.OpenFile("testdata/cities.bin")
+	require.NoError(b, err)
+	defer trf.Close()
+
+	ips := make([]net.IP, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		ips = append(ips, net.IPv4(81, 2, byte(i%8), byte(i)).To4())
+	}
+
+	for _, bc := range []struct {
+		name string
+		l    netrie.IPLookuper
+	}{
+		{"raw_file", trf},
+		{"cached_file", netrie.Cached(trf, 10000)},
+		{"cached_file_subnets", netrie.Cached(trf, 10000, func(o *netrie.CacheOptions) { o.Subnets = true })},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					bc.l.LookupIP(ips[i%len(ips)])
+					i++
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,571 @@
+package netrie
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression is a compression algorithm of a saved index.
+type Compression uint8
+
+// Supported compression algorithms.
+const (
+	CompressionNone Compression = iota
+	CompressionGzip
+	CompressionZstd
+)
+
+// DefaultBlockSize is a recommended block size of seekable compressed format.
+const DefaultBlockSize = 64 * 1024
+
+// ErrCompressedStream is returned by Open for an index compressed as a single stream, such index can only be
+// loaded in memory with Load or LoadFromFile.
+var ErrCompressedStream = errors.New("index is compressed as a stream, save it with BlockSize for random access")
+
+// SaveOptions configures Save and SaveCompressed.
+type SaveOptions struct {
+	Compression Compression // Default CompressionNone for Save and CompressionZstd for SaveCompressed.
+
+	// BlockSize enables seekable format with independently compressed blocks of this uncompressed size,
+	// that can be opened with Open or OpenFile for random access lookups, see DefaultBlockSize.
+	// Zero value compresses the whole index as a single stream.
+	BlockSize int
+
+	// FrontCodedNames stores sorted names with shared prefixes omitted, this reduces size of repetitive names
+	// and allows file-backed index to decode names on demand.
+	FrontCodedNames bool
+
+	// NamesOffsets stores names with offsets table, so that file-backed index reads names on demand
+	// instead of loading all of them on open, for example with idx.SaveToFile(name, func(o *SaveOptions) {
+	// o.NamesOffsets = true }). Like FrontCodedNames, it is not supported by earlier releases.
+	NamesOffsets bool
+}
+
+// SaveCompressed writes the compressed CIDRIndex data to the given io.Writer.
+//
+// Seekable format keeps header and metadata uncompressed and sets compression flag in the header version,
+// it is followed by a block index and blocks of nodes and names.
+// Compressed blocks are kept in memory before writing.
+func (idx *CIDRIndex[S]) SaveCompressed(w io.Writer, opts ...func(o *SaveOptions)) error {
+	o := SaveOptions{Compression: CompressionZstd}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return idx.saveWithOptions(w, o)
+}
+
+func (idx *CIDRIndex[S]) saveWithOptions(w io.Writer, o SaveOptions) error {
+	if idx.err != nil {
+		return fmt.Errorf("incomplete index: %w", idx.err)
+	}
+
+	if o.BlockSize > 0 {
+		return idx.saveBlocks(w, o)
+	}
+
+	switch o.Compression {
+	case CompressionNone:
+		return idx.save(w, o)
+	case CompressionGzip:
+		gw := gzip.NewWriter(w)
+
+		if err := idx.save(gw, o); err != nil {
+			return err
+		}
+
+		return gw.Close()
+	case CompressionZstd:
+		zw, err := zstd.NewWriter(w)
+		if err != nil {
+			return err
+		}
+
+		if err := idx.save(zw, o); err != nil {
+			_ = zw.Close()
+
+			return err
+		}
+
+		return zw.Close()
+	default:
+		return fmt.Errorf("unsupported compression: %d", o.Compression)
+	}
+}
+
+// SaveCompressedToFile saves the compressed CIDRIndex to a file.
+func (idx *CIDRIndex[S]) SaveCompressedToFile(filename string, opts ...func(o *SaveOptions)) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("create file to save index: %w", err)
+	}
+	defer file.Close()
+
+	w := bufio.NewWriter(file)
+
+	if err := idx.SaveCompressed(w, opts...); err != nil {
+		return fmt.Errorf("save file: %w", err)
+	}
+
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("flush file: %w", err)
+	}
+
+	return nil
+}
+
+// saveBlocks writes version 2 header, metadata, block size (uint32), number of blocks (uint32),
+// offsets of blocks (uint64, number of blocks + 1) relative to the first block, and compressed blocks.
+func (idx *CIDRIndex[S]) saveBlocks(w io.Writer, o SaveOptions) error {
+	if o.Compression != CompressionGzip && o.Compression != CompressionZstd {
+		return fmt.Errorf("unsupported compression for seekable format: %d", o.Compression)
+	}
+
+	flag, err := namesFlag(o)
+	if err != nil {
+		return err
+	}
+
+	bw := &blockWriter{size: o.BlockSize, c: o.Compression, offsets: []uint64{0}}
+
+	if err := idx.writePayload(bw, o); err != nil {
+		return err
+	}
+
+	if err := bw.flush(); err != nil {
+		return err
+	}
+
+	if err := idx.writeHeader(w, 2|uint32(o.Compression)<<16|flag); err != nil {
+		return err
+	}
+
+	ext := make([]byte, 8+8*len(bw.offsets))
+	binary.BigEndian.PutUint32(ext[0:4], uint32(o.BlockSize))
+	binary.BigEndian.PutUint32(ext[4:8], uint32(len(bw.offsets)-1))
+
+	for i, off := range bw.offsets {
+		binary.BigEndian.PutUint64(ext[8+8*i:], off)
+	}
+
+	if _, err := w.Write(ext); err != nil {
+		return fmt.Errorf("failed to write block index: %w", err)
+	}
+
+	if _, err := w.Write(bw.blocks.Bytes()); err != nil {
+		return fmt.Errorf("failed to write blocks: %w", err)
+	}
+
+	return nil
+}
+
+// blockWriter compresses written data in blocks of fixed size.
+type blockWriter struct {
+	size    int
+	c       Compression
+	buf     []byte
+	blocks  bytes.Buffer
+	offsets []uint64
+}
+
+func (bw *blockWriter) Write(p []byte) (int, error) {
+	bw.buf = append(bw.buf, p...)
+
+	for len(bw.buf) >= bw.size {
+		if err := bw.compress(bw.buf[:bw.size]); err != nil {
+			return 0, err
+		}
+
+		bw.buf = bw.buf[:copy(bw.buf, bw.buf[bw.size:])]
+	}
+
+	return len(p), nil
+}
+
+func (bw *blockWriter) flush() error {
+	if len(bw.buf) == 0 {
+		return nil
+	}
+
+	err := bw.compress(bw.buf)
+	bw.buf = bw.buf[:0]
+
+	return err
+}
+
+func (bw *blockWriter) compress(b []byte) error {
+	switch bw.c {
+	case CompressionGzip:
+		gw := gzip.NewWriter(&bw.blocks)
+
+		if _, err := gw.Write(b); err != nil {
+			return err
+		}
+
+		if err := gw.Close(); err != nil {
+			return err
+		}
+	case CompressionZstd:
+		enc, err := zstdEncoder()
+		if err != nil {
+			return err
+		}
+
+		bw.blocks.Write(enc.EncodeAll(b, nil))
+	}
+
+	bw.offsets = append(bw.offsets, uint64(bw.blocks.Len()))
+
+	return nil
+}
+
+var (
+	zstdEncoder = sync.OnceValues(func() (*zstd.Encoder, error) {
+		return zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+	})
+	zstdDecoder = sync.OnceValues(func() (*zstd.Decoder, error) {
+		return zstd.NewReader(nil, zstd.WithDecoderConcurrency(0), zstd.WithDecoderMaxMemory(maxBlockSize))
+	})
+)
+
+// maxBlockSize is a maximal uncompressed block size of seekable format accepted by readers.
+const maxBlockSize = 1 << 26
+
+// decompressBlock decompresses src into dst, uncompressed data larger than blockSize is rejected.
+func decompressBlock(c Compression, src, dst []byte, blockSize int64) ([]byte, error) {
+	var b []byte
+
+	switch c {
+	case CompressionGzip:
+		gr, err := gzip.NewReader(bytes.NewReader(src))
+		if err != nil {
+			return nil, err
+		}
+
+		buf := bytes.NewBuffer(dst[:0])
+
+		if _, err := buf.ReadFrom(io.LimitReader(gr, blockSize+1)); err != nil {
+			return nil, err
+		}
+
+		b = buf.Bytes()
+	case CompressionZstd:
+		var h zstd.Header
+		if err := h.Decode(src); err != nil {
+			return nil, err
+		}
+
+		if h.HasFCS && h.FrameContentSize > uint64(blockSize) {
+			return nil, fmt.Errorf("block content size %d exceeds block size %d", h.FrameContentSize, blockSize)
+		}
+
+		dec, err := zstdDecoder()
+		if err != nil {
+			return nil, err
+		}
+
+		if b, err = dec.DecodeAll(src, dst[:0]); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported compression: %d", c)
+	}
+
+	if int64(len(b)) > blockSize {
+		return nil, fmt.Errorf("block of %d bytes exceeds block size %d", len(b), blockSize)
+	}
+
+	return b, nil
+}
+
+// decompressStream detects gzip or zstd compressed stream by magic bytes.
+func decompressStream(r io.Reader) (io.Reader, func(), error) {
+	br := bufio.NewReader(r)
+
+	magic, _ := br.Peek(4)
+
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, nil, fmt.Errorf("gzip: %w", err)
+		}
+
+		return gr, func() {}, nil
+	case bytes.Equal(magic, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		zr, err := zstd.NewReader(br, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, nil, fmt.Errorf("zstd: %w", err)
+		}
+
+		return zr, zr.Close, nil
+	default:
+		return br, func() {}, nil
+	}
+}
+
+// isCompressedStream checks header for gzip or zstd magic bytes.
+func isCompressedStream(header []byte) bool {
+	return bytes.HasPrefix(header, []byte{0x1f, 0x8b}) || bytes.HasPrefix(header, []byte{0x28, 0xb5, 0x2f, 0xfd})
+}
+
+// blockIndex describes compressed blocks of seekable format.
+type blockIndex struct {
+	blockSize int64
+	offsets   []uint64
+}
+
+// unmarshal reads block index with block size and number of blocks in ext, followed by block offsets in r.
+func (bi *blockIndex) unmarshal(ext []byte, r io.Reader) error {
+	bi.blockSize = int64(binary.BigEndian.Uint32(ext[0:4]))
+	blocksLen := binary.BigEndian.Uint32(ext[4:8])
+
+	if bi.blockSize == 0 || bi.blockSize > maxBlockSize {
+		return fmt.Errorf("invalid block size: %d", bi.blockSize)
+	}
+
+	offsets, err := readFull(r, 8*(int64(blocksLen)+1))
+	if err != nil {
+		return fmt.Errorf("read block index: %w", err)
+	}
+
+	bi.offsets = make([]uint64, blocksLen+1)
+
+	for i := range bi.offsets {
+		bi.offsets[i] = binary.BigEndian.Uint64(offsets[8*i:])
+
+		if i > 0 && bi.offsets[i] < bi.offsets[i-1] {
+			return fmt.Errorf("invalid block index: offset %d decreases", i)
+		}
+
+		if bi.offsets[i] > math.MaxInt64/2 {
+			return fmt.Errorf("invalid block index: offset %d is too large", i)
+		}
+	}
+
+	return nil
+}
+
+// checkLen checks that every block except the last one has exactly blockSize bytes,
+// so that payload offsets can be mapped to blocks.
+func (bi *blockIndex) checkLen(i, n int) error {
+	if i < len(bi.offsets)-2 && int64(n) != bi.blockSize {
+		return fmt.Errorf("block %d of %d bytes, only last block can be smaller than block size %d", i, n, bi.blockSize)
+	}
+
+	return nil
+}
+
+// blockStreamReader reads blocks of seekable format sequentially.
+type blockStreamReader struct {
+	r   io.Reader
+	c   Compression
+	bi  blockIndex
+	i   int
+	cur []byte
+	buf []byte
+}
+
+func newBlockStreamReader(r io.Reader, c Compression) (*blockStreamReader, error) {
+	ext := make([]byte, 8)
+	if _, err := io.ReadFull(r, ext); err != nil {
+		return nil, fmt.Errorf("read block index: %w", err)
+	}
+
+	s := &blockStreamReader{r: r, c: c}
+
+	if err := s.bi.unmarshal(ext, r); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *blockStreamReader) Read(p []byte) (int, error) {
+	for len(s.cur) == 0 {
+		if s.i >= len(s.bi.offsets)-1 {
+			return 0, io.EOF
+		}
+
+		compressed, err := readFull(s.r, int64(s.bi.offsets[s.i+1]-s.bi.offsets[s.i]))
+		if err != nil {
+			return 0, fmt.Errorf("read block %d: %w", s.i, err)
+		}
+
+		b, err := decompressBlock(s.c, compressed, s.buf, s.bi.blockSize)
+		if err != nil {
+			return 0, fmt.Errorf("decompress block %d: %w", s.i, err)
+		}
+
+		if err := s.bi.checkLen(s.i, len(b)); err != nil {
+			return 0, err
+		}
+
+		s.buf = b
+		s.cur = b
+		s.i++
+	}
+
+	n := copy(p, s.cur)
+	s.cur = s.cur[n:]
+
+	return n, nil
+}
+
+// blockReaderAt provides random access to uncompressed payload of seekable format,
+// payload starts at base offset, recently used blocks are cached.
+type blockReaderAt struct {
+	r            io.ReaderAt
+	c            Compression
+	bi           blockIndex
+	base         int64
+	blocksOffset int64
+
+	mu     sync.Mutex
+	cached []cachedBlock // Most recently used first.
+	maxLen int
+}
+
+type cachedBlock struct {
+	i    int
+	data []byte
+}
+
+func newBlockReaderAt(r io.ReaderAt, h hd, cacheBlocks int) (*blockReaderAt, error) {
+	base := 20 + int64(h.metadataLen)
+
+	ext := make([]byte, 8)
+	if _, err := r.ReadAt(ext, base); err != nil {
+		return nil, fmt.Errorf("read block index: %w", err)
+	}
+
+	if cacheBlocks <= 0 {
+		cacheBlocks = 1
+	}
+
+	br := &blockReaderAt{r: r, c: h.compression, base: base, maxLen: cacheBlocks}
+
+	if err := br.bi.unmarshal(ext, io.NewSectionReader(r, base+8, math.MaxInt64-base-8)); err != nil {
+		return nil, err
+	}
+
+	br.blocksOffset = base + 8 + 8*int64(len(br.bi.offsets))
+
+	return br, nil
+}
+
+// payloadEnd checks that compressed blocks end at size unless it is negative
+// and that all blocks have valid length, and returns the end offset of uncompressed payload.
+func (br *blockReaderAt) payloadEnd(size int64) (int64, error) {
+	blocks := len(br.bi.offsets) - 1
+
+	if blocksEnd := br.blocksOffset + int64(br.bi.offsets[blocks]); size >= 0 && blocksEnd != size {
+		return 0, invalid("header", -1, "compressed blocks end at %d, data ends at %d", blocksEnd, size)
+	}
+
+	if blocks == 0 {
+		return br.base, nil
+	}
+
+	br.mu.Lock()
+	defer br.mu.Unlock()
+
+	var last []byte
+
+	for i := 0; i < blocks; i++ {
+		b, err := br.block(i)
+		if err != nil {
+			return 0, &ValidationError{Section: "header", Index: -1, Err: err}
+		}
+
+		last = b
+	}
+
+	return br.base + br.bi.blockSize*int64(blocks-1) + int64(len(last)), nil
+}
+
+// block returns uncompressed block, br.mu must be held.
+func (br *blockReaderAt) block(i int) ([]byte, error) {
+	for j, cb := range br.cached {
+		if cb.i == i {
+			copy(br.cached[1:j+1], br.cached[:j])
+			br.cached[0] = cb
+
+			return cb.data, nil
+		}
+	}
+
+	if i >= len(br.bi.offsets)-1 {
+		return nil, io.EOF
+	}
+
+	size := int64(br.bi.offsets[i+1] - br.bi.offsets[i])
+
+	compressed, err := readFull(io.NewSectionReader(br.r, br.blocksOffset+int64(br.bi.offsets[i]), size), size)
+	if err != nil {
+		return nil, fmt.Errorf("read block %d: %w", i, err)
+	}
+
+	var dst []byte
+
+	if len(br.cached) == br.maxLen {
+		dst = br.cached[len(br.cached)-1].data
+		br.cached = br.cached[:len(br.cached)-1]
+	}
+
+	data, err := decompressBlock(br.c, compressed, dst, br.bi.blockSize)
+	if err != nil {
+		return nil, fmt.Errorf("decompress block %d: %w", i, err)
+	}
+
+	if err := br.bi.checkLen(i, len(data)); err != nil {
+		return nil, err
+	}
+
+	br.cached = append(br.cached, cachedBlock{})
+	copy(br.cached[1:], br.cached)
+	br.cached[0] = cachedBlock{i: i, data: data}
+
+	return data, nil
+}
+
+func (br *blockReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < br.base {
+		return 0, fmt.Errorf("offset %d is out of compressed payload", off)
+	}
+
+	br.mu.Lock()
+	defer br.mu.Unlock()
+
+	off -= br.base
+	n := 0
+
+	for n < len(p) {
+		i := int((off + int64(n)) / br.bi.blockSize)
+
+		b, err := br.block(i)
+		if err != nil {
+			return n, err
+		}
+
+		pos := (off + int64(n)) % br.bi.blockSize
+		if pos >= int64(len(b)) {
+			return n, io.EOF
+		}
+
+		n += copy(p[n:], b[pos:])
+	}
+
+	return n, nil
+}
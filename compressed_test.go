@@ -0,0 +1,124 @@
+package netrie_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vearutop/netrie"
+)
+
+func loadCities(t *testing.T) *netrie.CIDRIndex[int16] {
+	t.Helper()
+
+	l, err := netrie.LoadFromFile("testdata/cities.bin")
+	require.NoError(t, err)
+
+	idx, ok := l.(*netrie.CIDRIndex[int16])
+	require.True(t, ok)
+
+	return idx
+}
+
+func assertCities(t *testing.T, l netrie.IPLookuper) {
+	t.Helper()
+
+	assert.Equal(t, "GB:Boxford", l.Lookup("2.125.160.217"))
+	assert.Equal(t, "GB:London", l.Lookup("81.2.69.145"))
+	assert.Equal(t, "US:San Diego", l.Lookup("2001:480:10::1"))
+	assert.Equal(t, "", l.Lookup("143.198.196.44"))
+}
+
+func TestCIDRIndex_SaveCompressed(t *testing.T) {
+	idx := loadCities(t)
+
+	plain := bytes.NewBuffer(nil)
+	require.NoError(t, idx.Save(plain))
+
+	for _, tc := range []struct {
+		name string
+		opt  func(o *netrie.SaveOptions)
+	}{
+		{"zstd", func(o *netrie.SaveOptions) {}},
+		{"gzip", func(o *netrie.SaveOptions) { o.Compression = netrie.CompressionGzip }},
+		{"none", func(o *netrie.SaveOptions) { o.Compression = netrie.CompressionNone }},
+		{"zstd_blocks", func(o *netrie.SaveOptions) { o.BlockSize = 1024 }},
+		{"gzip_blocks", func(o *netrie.SaveOptions) {
+			o.Compression = netrie.CompressionGzip
+			o.BlockSize = netrie.DefaultBlockSize
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			buf := bytes.NewBuffer(nil)
+			require.NoError(t, idx.SaveCompressed(buf, tc.opt))
+
+			if tc.name != "none" {
+				assert.Less(t, buf.Len(), plain.Len()/2)
+			}
+
+			l, err := netrie.Load(bytes.NewReader(buf.Bytes()))
+			require.NoError(t, err)
+
+			assert.Equal(t, idx.Len(), l.Len())
+			assert.Equal(t, idx.LenNames(), l.LenNames())
+			assertCities(t, l)
+		})
+	}
+}
+
+func TestOpenFile_compressed(t *testing.T) {
+	idx := loadCities(t)
+	dir := t.TempDir()
+
+	fn := filepath.Join(dir, "cities.bin.zst")
+	require.NoError(t, idx.SaveCompressedToFile(fn, func(o *netrie.SaveOptions) {
+		o.BlockSize = 512
+	}))
+
+	l, err := netrie.LoadFromFile(fn)
+	require.NoError(t, err)
+	assertCities(t, l)
+
+	trf, err := netrie.OpenFile(fn, func(o *netrie.Options) {
+		o.BlockCache = 2
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, idx.Len(), trf.Len())
+	assert.Equal(t, idx.LenNames(), trf.LenNames())
+
+	for i := 0; i < 3; i++ {
+		assertCities(t, trf)
+	}
+
+	st, ok := trf.(netrie.ReadStatsProvider)
+	require.True(t, ok)
+	assert.Positive(t, st.ReadStats().Reads)
+
+	require.NoError(t, trf.Close())
+
+	// Stream compressed index can only be loaded in memory.
+	fn = filepath.Join(dir, "cities.bin.gz")
+	require.NoError(t, idx.SaveCompressedToFile(fn, func(o *netrie.SaveOptions) {
+		o.Compression = netrie.CompressionGzip
+	}))
+
+	_, err = netrie.OpenFile(fn)
+	require.ErrorIs(t, err, netrie.ErrCompressedStream)
+
+	l, err = netrie.LoadFromFile(fn)
+	require.NoError(t, err)
+	assertCities(t, l)
+
+	// Corrupted header.
+	b, err := os.ReadFile(filepath.Join(dir, "cities.bin.zst"))
+	require.NoError(t, err)
+
+	b[1] = 0x07 // Unknown compression.
+
+	_, err = netrie.Load(bytes.NewReader(b))
+	require.EqualError(t, err, "unmarshal header: unsupported compression: 7")
+}
@@ -0,0 +1,114 @@
+package netrie
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"slices"
+)
+
+// ToLarge copies the index to a CIDRIndex for up to 2^32 networks.
+// Adding can be continued with the copy after ErrTooManyNames.
+func ToLarge(idx *CIDRIndex[int16]) *CIDRIndex[int32] {
+	return convertIndex[int32](idx)
+}
+
+// ToSmall copies the index to a CIDRIndex for up to 2^16 networks.
+// Returns ErrTooManyNames if names do not fit in the smaller namespace.
+func ToSmall(idx *CIDRIndex[int32]) (*CIDRIndex[int16], error) {
+	if len(idx.names) > math.MaxInt16 {
+		return nil, fmt.Errorf("%w: %d names, up to %d supported", ErrTooManyNames, len(idx.names), math.MaxInt16)
+	}
+
+	return convertIndex[int16](idx), nil
+}
+
+func convertIndex[T, S int16 | int32](idx *CIDRIndex[S]) *CIDRIndex[T] {
+	res := &CIDRIndex[T]{
+		meta:     idx.meta,
+		nodes:    make([]trieNode[T], len(idx.nodes)),
+		names:    slices.Clone(idx.names),
+		total:    idx.total,
+		idByName: make(map[string]T, len(idx.idByName)),
+		shared:   idx.shared,
+	}
+
+	for i, node := range idx.nodes {
+		res.nodes[i] = trieNode[T]{children: node.children, id: T(node.id), maskLen: node.maskLen}
+	}
+
+	for name, id := range idx.idByName {
+		res.idByName[name] = T(id)
+	}
+
+	return res
+}
+
+// Convert re-encodes index data from r to w with nodes of large (up to 2^32 networks) or small namespace.
+// Index data of any format is accepted, it is written with Save, or with SaveCompressed if options are provided.
+// Returns ErrTooManyNames if names do not fit in the small namespace.
+func Convert(r io.Reader, w io.Writer, large bool, opts ...func(o *SaveOptions)) error {
+	l, err := Load(r)
+	if err != nil {
+		return fmt.Errorf("load index: %w", err)
+	}
+
+	switch idx := l.(type) {
+	case *CIDRIndex[int16]:
+		if large {
+			return saveConverted(ToLarge(idx), w, opts)
+		}
+
+		return saveConverted(idx, w, opts)
+	case *CIDRIndex[int32]:
+		if large {
+			return saveConverted(idx, w, opts)
+		}
+
+		small, err := ToSmall(idx)
+		if err != nil {
+			return err
+		}
+
+		return saveConverted(small, w, opts)
+	default:
+		return fmt.Errorf("unexpected index type: %T", l)
+	}
+}
+
+func saveConverted[S int16 | int32](idx *CIDRIndex[S], w io.Writer, opts []func(o *SaveOptions)) error {
+	if len(opts) > 0 {
+		return idx.SaveCompressed(w, opts...)
+	}
+
+	return idx.Save(w)
+}
+
+// ConvertFile re-encodes index file src to dst with nodes of large or small namespace, see Convert.
+func ConvertFile(src, dst string, large bool, opts ...func(o *SaveOptions)) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("create file to save index: %w", err)
+	}
+	defer out.Close()
+
+	w := bufio.NewWriter(out)
+
+	if err := Convert(bufio.NewReader(in), w, large, opts...); err != nil {
+		return err
+	}
+
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("flush file: %w", err)
+	}
+
+	return out.Close()
+}
@@ -0,0 +1,117 @@
+package netrie_test
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/netip"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vearutop/netrie"
+)
+
+// addNames adds a /32 prefix for each of n names.
+func addNames(t *testing.T, a netrie.Adder, n int) {
+	t.Helper()
+
+	for i := 0; i < n; i++ {
+		require.NoError(t, a.AddCIDR(fmt.Sprintf("10.%d.%d.0/32", i>>8, i&0xff), fmt.Sprintf("name-%d", i)))
+	}
+}
+
+func TestCIDRIndex_tooManyNames(t *testing.T) {
+	idx := netrie.NewCIDRIndex()
+	addNames(t, idx, math.MaxInt16)
+
+	require.ErrorIs(t, idx.AddCIDR("192.168.0.0/16", "one more"), netrie.ErrTooManyNames)
+	assert.Equal(t, math.MaxInt16, idx.LenNames())
+	assert.Equal(t, "", idx.Lookup("192.168.1.1"))
+
+	// Existing names can still be added.
+	require.NoError(t, idx.AddCIDR("192.168.0.0/16", "name-1"))
+	assert.Equal(t, "name-1", idx.Lookup("192.168.1.1"))
+
+	// AddNet keeps the first error, incomplete index can not be saved.
+	require.NoError(t, idx.Err())
+	require.NoError(t, idx.AddRange(netip.MustParseAddr("172.16.0.0"), netip.MustParseAddr("172.16.0.255"), "name-2"))
+	require.ErrorIs(t, idx.AddRange(netip.MustParseAddr("172.17.0.0"), netip.MustParseAddr("172.17.0.255"), "new"),
+		netrie.ErrTooManyNames)
+
+	ipNet := mustNet(t, "172.18.0.0/16")
+	idx.AddNet(ipNet, "other")
+	require.ErrorIs(t, idx.Err(), netrie.ErrTooManyNames)
+	require.ErrorIs(t, idx.Save(&bytes.Buffer{}), netrie.ErrTooManyNames)
+
+	// Adding is continued with a large index.
+	large := netrie.ToLarge(idx)
+	large.AddNet(ipNet, "other")
+	require.NoError(t, large.Err())
+	assert.Equal(t, "other", large.Lookup("172.18.1.1"))
+	assert.Equal(t, "name-1", large.Lookup("192.168.1.1"))
+	assert.Equal(t, "name-300", large.Lookup("10.1.44.0"))
+	assert.Equal(t, math.MaxInt16+1, large.LenNames())
+
+	_, err := netrie.ToSmall(large)
+	require.ErrorIs(t, err, netrie.ErrTooManyNames)
+
+	b := netrie.NewBuilder()
+	addNames(t, b, math.MaxInt16)
+	require.ErrorIs(t, b.AddCIDR("192.168.0.0/16", "one more"), netrie.ErrTooManyNames)
+
+	sb := netrie.NewSortedBuilder()
+	addNames(t, sb, math.MaxInt16)
+	require.ErrorIs(t, sb.AddCIDR("192.168.0.0/16", "one more"), netrie.ErrTooManyNames)
+	require.NoError(t, sb.AddCIDR("192.168.0.0/16", "name-0"))
+	assert.Equal(t, "name-0", sb.Finish().Lookup("192.168.1.1"))
+}
+
+func TestConvert(t *testing.T) {
+	idx := netrie.NewCIDRIndex()
+	require.NoError(t, idx.AddCIDR("10.0.0.0/8", "foo"))
+	require.NoError(t, idx.AddCIDR("2001:db8::/32", "bar"))
+	idx.Metadata().Name = "test"
+
+	small := bytes.NewBuffer(nil)
+	require.NoError(t, idx.SaveCompressed(small, func(o *netrie.SaveOptions) {
+		o.BlockSize = 64
+	}))
+
+	large := bytes.NewBuffer(nil)
+	require.NoError(t, netrie.Convert(bytes.NewReader(small.Bytes()), large, true))
+
+	l, err := netrie.Load(bytes.NewReader(large.Bytes()))
+	require.NoError(t, err)
+	require.IsType(t, &netrie.CIDRIndex[int32]{}, l)
+	assert.Equal(t, "foo", l.Lookup("10.1.2.3"))
+	assert.Equal(t, "bar", l.Lookup("2001:db8::1"))
+	assert.Equal(t, "test", l.Metadata().Name)
+
+	back := bytes.NewBuffer(nil)
+	require.NoError(t, netrie.Convert(bytes.NewReader(large.Bytes()), back, false, func(o *netrie.SaveOptions) {
+		o.Compression = netrie.CompressionGzip
+	}))
+
+	_, err = netrie.Open(bytes.NewReader(back.Bytes()))
+	require.ErrorIs(t, err, netrie.ErrCompressedStream)
+
+	l, err = netrie.Load(back)
+	require.NoError(t, err)
+	require.IsType(t, &netrie.CIDRIndex[int16]{}, l)
+	assert.Equal(t, "foo", l.Lookup("10.1.2.3"))
+	assert.Equal(t, 2, l.Len())
+
+	src := filepath.Join(t.TempDir(), "small.bin")
+	dst := filepath.Join(t.TempDir(), "large.bin")
+	require.NoError(t, idx.SaveToFile(src))
+	require.NoError(t, netrie.ConvertFile(src, dst, true))
+
+	f, err := netrie.OpenFile(dst)
+	require.NoError(t, err)
+
+	defer f.Close()
+
+	assert.Equal(t, "bar", f.Lookup("2001:db8::1"))
+}
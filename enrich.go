@@ -0,0 +1,298 @@
+package netrie
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+// LookupBatch finds names for all ips using l and stores them in out, out must be at least as long as ips.
+// It uses BatchIPLookuper if l implements it, or falls back to LookupIP.
+func LookupBatch(l IPLookuper, ips []netip.Addr, out []string) {
+	if bl, ok := l.(BatchIPLookuper); ok {
+		bl.LookupBatch(ips, out)
+
+		return
+	}
+
+	lookupBatch(ips, out, func(addr netip.Addr) string {
+		if !addr.IsValid() {
+			return ""
+		}
+
+		return l.LookupIP(net.IP(addr.Unmap().AsSlice()))
+	})
+}
+
+// lookupBatch resolves ips into out with fn, calling it once per unique address in sorted order.
+func lookupBatch(ips []netip.Addr, out []string, fn func(addr netip.Addr) string) {
+	_ = out[:len(ips)] // Fail early if out is too short.
+
+	order := make([]int, len(ips))
+	for i := range order {
+		order[i] = i
+	}
+
+	slices.SortFunc(order, func(a, b int) int {
+		return ips[a].Compare(ips[b])
+	})
+
+	var (
+		prev     netip.Addr
+		prevName string
+	)
+
+	for k, i := range order {
+		if k > 0 && ips[i] == prev {
+			out[i] = prevName
+
+			continue
+		}
+
+		prev = ips[i]
+		prevName = fn(prev)
+		out[i] = prevName
+	}
+}
+
+// EnrichFormat defines the layout of lines processed by Enrich.
+type EnrichFormat int
+
+const (
+	// EnrichPlain expects a single IP per line and writes "<ip><separator><name>".
+	EnrichPlain EnrichFormat = iota
+	// EnrichTSV expects separated values with the IP in EnrichOptions.Column, name is appended as the last column.
+	EnrichTSV
+	// EnrichJSON expects JSON objects with the IP in EnrichOptions.Field, name is added as EnrichOptions.NameField.
+	EnrichJSON
+)
+
+// EnrichOptions configures Enrich.
+type EnrichOptions struct {
+	Format    EnrichFormat
+	Separator string // Default "\t", used for EnrichPlain and EnrichTSV.
+	Column    int    // Zero-based IP column for EnrichTSV.
+	Field     string // Default "ip", IP field for EnrichJSON.
+	NameField string // Default "name", added field for EnrichJSON.
+	BatchSize int    // Default 4096.
+
+	// MaxLineSize is the max length of a line with the line break, default 1 MiB.
+	// Longer lines are written unchanged without a name.
+	MaxLineSize int
+}
+
+// Enrich reads lines from r, resolves their IPs with l in batches and writes lines with appended names to w.
+// Lines without a valid IP get an empty name, JSON lines that are not objects and lines longer than
+// EnrichOptions.MaxLineSize are written unchanged.
+func Enrich(l IPLookuper, r io.Reader, w io.Writer, opts ...func(o *EnrichOptions)) error {
+	o := EnrichOptions{
+		Separator:   "\t",
+		Field:       "ip",
+		NameField:   "name",
+		BatchSize:   4096,
+		MaxLineSize: 1024 * 1024,
+	}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.BatchSize <= 0 {
+		o.BatchSize = 1
+	}
+
+	nameField, err := json.Marshal(o.NameField)
+	if err != nil {
+		return fmt.Errorf("encode name field: %w", err)
+	}
+
+	br := bufio.NewReaderSize(r, o.MaxLineSize)
+	bw := bufio.NewWriter(w)
+
+	lines := make([]string, 0, o.BatchSize)
+	ips := make([]netip.Addr, 0, o.BatchSize)
+	names := make([]string, o.BatchSize)
+
+	flush := func() error {
+		LookupBatch(l, ips, names)
+
+		for i, line := range lines {
+			if err := o.writeLine(bw, line, names[i], nameField); err != nil {
+				return err
+			}
+		}
+
+		lines = lines[:0]
+		ips = ips[:0]
+
+		return nil
+	}
+
+	for {
+		b, err := br.ReadSlice('\n')
+
+		if errors.Is(err, bufio.ErrBufferFull) {
+			// Oversized line is written unchanged after preceding lines.
+			if err := flush(); err != nil {
+				return err
+			}
+
+			if err := copyLine(bw, br, b); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if len(b) > 0 {
+			line := string(bytes.TrimSuffix(bytes.TrimSuffix(b, []byte("\n")), []byte("\r")))
+
+			lines = append(lines, line)
+			ips = append(ips, o.parseLine(line))
+
+			if len(lines) == o.BatchSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return fmt.Errorf("read lines: %w", err)
+		}
+	}
+
+	if err := flush(); err != nil {
+		return err
+	}
+
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("flush output: %w", err)
+	}
+
+	return nil
+}
+
+// copyLine writes the beginning of a line and the rest of it from r to w.
+func copyLine(w *bufio.Writer, r *bufio.Reader, b []byte) error {
+	for {
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+
+		if bytes.HasSuffix(b, []byte("\n")) {
+			return nil
+		}
+
+		var err error
+
+		b, err = r.ReadSlice('\n')
+
+		switch {
+		case errors.Is(err, bufio.ErrBufferFull):
+		case errors.Is(err, io.EOF):
+			if _, err := w.Write(b); err != nil {
+				return err
+			}
+
+			return w.WriteByte('\n')
+		case err != nil:
+			return fmt.Errorf("read lines: %w", err)
+		}
+	}
+}
+
+func (o *EnrichOptions) parseLine(line string) netip.Addr {
+	var ipStr string
+
+	switch o.Format {
+	case EnrichPlain:
+		ipStr = line
+	case EnrichTSV:
+		ipStr = column(line, o.Separator, o.Column)
+	case EnrichJSON:
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(line), &fields); err != nil {
+			return netip.Addr{}
+		}
+
+		if err := json.Unmarshal(fields[o.Field], &ipStr); err != nil {
+			return netip.Addr{}
+		}
+	}
+
+	addr, err := netip.ParseAddr(strings.TrimSpace(ipStr))
+	if err != nil {
+		return netip.Addr{}
+	}
+
+	return addr
+}
+
+func (o *EnrichOptions) writeLine(w *bufio.Writer, line, name string, nameField []byte) error {
+	if o.Format != EnrichJSON {
+		_, err := w.WriteString(line + o.Separator + name + "\n")
+
+		return err
+	}
+
+	body := strings.TrimRight(line, " \t\r")
+	if !strings.HasPrefix(strings.TrimSpace(body), "{") || !strings.HasSuffix(body, "}") {
+		_, err := w.WriteString(line + "\n")
+
+		return err
+	}
+
+	body = body[:len(body)-1]
+
+	b := make([]byte, 0, len(body)+len(nameField)+len(name)+4)
+	b = append(b, body...)
+
+	if !bytes.HasSuffix(bytes.TrimSpace(b), []byte("{")) {
+		b = append(b, ',')
+	}
+
+	b = append(b, nameField...)
+	b = append(b, ':')
+
+	nameJSON, err := json.Marshal(name)
+	if err != nil {
+		return err
+	}
+
+	b = append(b, nameJSON...)
+	b = append(b, '}', '\n')
+
+	_, err = w.Write(b)
+
+	return err
+}
+
+// column returns n-th sep-separated value of line, or "" if there are not enough values.
+func column(line, sep string, n int) string {
+	for i := 0; i < n; i++ {
+		pos := strings.Index(line, sep)
+		if pos == -1 {
+			return ""
+		}
+
+		line = line[pos+len(sep):]
+	}
+
+	if pos := strings.Index(line, sep); pos != -1 {
+		return line[:pos]
+	}
+
+	return line
+}
@@ -0,0 +1,151 @@
+package netrie_test
+
+import (
+	"bytes"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vearutop/netrie"
+)
+
+func TestLookupBatch(t *testing.T) {
+	ips := []netip.Addr{
+		netip.MustParseAddr("81.2.69.145"),
+		netip.MustParseAddr("2.125.160.217"),
+		{},
+		netip.MustParseAddr("2001:480:10::1"),
+		netip.MustParseAddr("81.2.69.145"),
+		netip.MustParseAddr("::ffff:81.2.69.145"),
+		netip.MustParseAddr("143.198.196.44"),
+	}
+	expected := []string{"GB:London", "GB:Boxford", "", "US:San Diego", "GB:London", "GB:London", ""}
+
+	tr, err := netrie.LoadFromFile("testdata/cities.bin")
+	require.NoError(t, err)
+
+	trf, err := netrie.OpenFile("testdata/cities.bin")
+	require.NoError(t, err)
+	defer trf.Close()
+
+	for _, l := range []netrie.IPLookuper{tr, trf, lookuperOnly{tr}} {
+		out := make([]string, len(ips))
+		netrie.LookupBatch(l, ips, out)
+		assert.Equal(t, expected, out)
+	}
+}
+
+type lookuperOnly struct {
+	netrie.IPLookuper
+}
+
+func TestEnrich(t *testing.T) {
+	tr := netrie.NewCIDRIndex()
+	require.NoError(t, tr.AddCIDR("192.168.1.0/24", "net1"))
+	require.NoError(t, tr.AddCIDR("2001:db8::/32", "net2"))
+
+	for _, tc := range []struct {
+		name     string
+		opt      func(o *netrie.EnrichOptions)
+		in, want string
+	}{
+		{
+			name: "plain",
+			opt:  func(o *netrie.EnrichOptions) {},
+			in:   "192.168.1.1\n8.8.8.8\ninvalid\n2001:db8::1\n",
+			want: "192.168.1.1\tnet1\n8.8.8.8\t\ninvalid\t\n2001:db8::1\tnet2\n",
+		},
+		{
+			name: "tsv",
+			opt: func(o *netrie.EnrichOptions) {
+				o.Format = netrie.EnrichTSV
+				o.Column = 1
+			},
+			in:   "a\t192.168.1.1\tb\nc\t2001:db8::1\nd\n",
+			want: "a\t192.168.1.1\tb\tnet1\nc\t2001:db8::1\tnet2\nd\t\n",
+		},
+		{
+			name: "csv",
+			opt: func(o *netrie.EnrichOptions) {
+				o.Format = netrie.EnrichTSV
+				o.Separator = ","
+			},
+			in:   "192.168.1.1,foo\n",
+			want: "192.168.1.1,foo,net1\n",
+		},
+		{
+			name: "json",
+			opt: func(o *netrie.EnrichOptions) {
+				o.Format = netrie.EnrichJSON
+				o.Field = "addr"
+				o.NameField = "net"
+				o.BatchSize = 2
+			},
+			in: `{"addr":"192.168.1.1","n":1}` + "\n" + `{"addr":"1.1.1.1"}` + "\n" + `{}` + "\n" + `[1]` + "\n" +
+				`{"addr":"2001:db8::1"}` + "\n",
+			want: `{"addr":"192.168.1.1","n":1,"net":"net1"}` + "\n" + `{"addr":"1.1.1.1","net":""}` + "\n" +
+				`{"net":""}` + "\n" + `[1]` + "\n" + `{"addr":"2001:db8::1","net":"net2"}` + "\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			out := bytes.NewBuffer(nil)
+			require.NoError(t, netrie.Enrich(tr, strings.NewReader(tc.in), out, tc.opt))
+			assert.Equal(t, tc.want, out.String())
+		})
+	}
+}
+
+func TestEnrich_longLine(t *testing.T) {
+	tr := netrie.NewCIDRIndex()
+	require.NoError(t, tr.AddCIDR("192.168.1.0/24", "net1"))
+
+	long := `{"ip":"192.168.1.1","data":"` + strings.Repeat("x", 100) + `"}`
+	in := `{"ip":"192.168.1.1"}` + "\n" + long + "\r\n" + `{"ip":"192.168.1.2"}` + "\n" + long
+	want := `{"ip":"192.168.1.1","name":"net1"}` + "\n" + long + "\r\n" + `{"ip":"192.168.1.2","name":"net1"}` + "\n" +
+		long + "\n"
+
+	out := bytes.NewBuffer(nil)
+	require.NoError(t, netrie.Enrich(tr, strings.NewReader(in), out, func(o *netrie.EnrichOptions) {
+		o.Format = netrie.EnrichJSON
+		o.MaxLineSize = 32
+	}))
+	assert.Equal(t, want, out.String())
+
+	// Default limit.
+	long = "192.168.1.1\t" + strings.Repeat("x", 2*1024*1024)
+	out.Reset()
+	require.NoError(t, netrie.Enrich(tr, strings.NewReader("192.168.1.1\n"+long+"\n192.168.1.2\n"), out,
+		func(o *netrie.EnrichOptions) { o.Format = netrie.EnrichTSV }))
+	assert.True(t, out.String() == "192.168.1.1\tnet1\n"+long+"\n192.168.1.2\tnet1\n")
+}
+
+func BenchmarkLookupBatch(b *testing.B) {
+	trf, err := netrie.OpenFile("testdata/cities.bin")
+	require.NoError(b, err)
+	defer trf.Close()
+
+	ips := make([]netip.Addr, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		ips = append(ips, netip.AddrFrom4([4]byte{81, 2, byte(i % 8), byte(i)}))
+	}
+
+	out := make([]string, len(ips))
+
+	b.Run("batch", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			netrie.LookupBatch(trf, ips, out)
+		}
+	})
+
+	b.Run("single", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for j, ip := range ips {
+				out[j] = trf.LookupIP(ip.AsSlice())
+			}
+		}
+	})
+}
@@ -3,19 +3,23 @@ package netrie
 import (
 	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
+	"net/netip"
 	"os"
 	"sync"
+	"sync/atomic"
 )
 
 // CIDRIndexFile is the trie structure for CIDR lookups.
 type CIDRIndexFile[S int16 | int32] struct {
 	meta Metadata
 
-	mu sync.Mutex
-	r  io.ReaderAt
+	mu  sync.Mutex
+	r   io.ReaderAt
+	src *countingReaderAt
 
 	nodesOffset int64
 	nodeSize    int64
@@ -26,13 +30,23 @@ type CIDRIndexFile[S int16 | int32] struct {
 
 	names []string
 	total int
+
+	// Names with offsets table or front-coded names are read on demand.
+	frontCoded      bool
+	bucketSize      int
+	namesDataOffset int64
+	cache           *nameCache
+	maxNameLen      int64
+
+	resident residentCache
 }
 
-func newCIDRIndexFile[S int16 | int32](r io.ReaderAt, h hd) (*CIDRIndexFile[S], error) {
+func newCIDRIndexFile[S int16 | int32](r io.ReaderAt, src *countingReaderAt, h hd, o Options, end int64) (*CIDRIndexFile[S], error) {
 	nodesOffset := 20 + int64(h.metadataLen)
 
 	idx := &CIDRIndexFile[S]{}
 	idx.r = r
+	idx.src = src
 	idx.nodeSize = h.nodeSize
 	idx.nodesLen = int64(h.nodesLen)
 	idx.nodesOffset = nodesOffset
@@ -40,7 +54,41 @@ func newCIDRIndexFile[S int16 | int32](r io.ReaderAt, h hd) (*CIDRIndexFile[S],
 	idx.namesLen = int64(h.namesLen)
 	idx.meta = h.meta
 	idx.total = int(h.total)
+	idx.maxNameLen = int64(o.MaxNameLen)
+
+	switch {
+	case h.namesOffsets:
+		idx.namesDataOffset = idx.namesOffset + 4*(idx.namesLen+1)
+	case h.frontCodedNames:
+		bucketSize, bucketsLen, err := readFrontCodedHeader(int(h.namesLen), func(b []byte) error {
+			if n, err := r.ReadAt(b, idx.namesOffset); n < len(b) {
+				return err
+			}
+
+			return nil
+		})
+		if err != nil {
+			return nil, &ValidationError{Section: "names", Index: -1, Err: err}
+		}
+
+		idx.frontCoded = true
+		idx.bucketSize = bucketSize
+		idx.namesDataOffset = idx.namesOffset + 8 + 4*int64(bucketsLen+1)
+	}
+
+	if o.Strict {
+		if err := idx.validate(end); err != nil {
+			return nil, err
+		}
+	}
+
+	if idx.namesDataOffset != 0 {
+		idx.cache = newNameCache(o.NameCache)
 
+		return idx, nil
+	}
+
+	// Names of legacy format are loaded at once.
 	if err := idx.readNames(); err != nil {
 		return nil, err
 	}
@@ -81,28 +129,31 @@ func (idx *CIDRIndexFile[S]) Lookup(ipStr string) string {
 
 func (idx *CIDRIndexFile[S]) readNames() error {
 	offset := idx.namesOffset
-	idx.names = make([]string, idx.namesLen)
+	idx.names = make([]string, 0, min(idx.namesLen, loadChunk))
 
 	// Read names
 	for i := 0; i < int(idx.namesLen); i++ {
 		// Read string length (int32)
 		nameLenBuf := make([]byte, 4)
-		_, err := idx.r.ReadAt(nameLenBuf, offset)
-		if err != nil && err != io.EOF {
+		if n, err := idx.r.ReadAt(nameLenBuf, offset); n < len(nameLenBuf) {
 			return fmt.Errorf("read name %d length: %w", i, err)
 		}
 
-		nameLen := int(binary.BigEndian.Uint32(nameLenBuf))
+		nameLen := int64(binary.BigEndian.Uint32(nameLenBuf))
 		offset += 4
 
+		if nameLen > idx.maxNameLen {
+			return fmt.Errorf("%w: name %d of %d bytes, max %d", ErrLimitExceeded, i, nameLen, idx.maxNameLen)
+		}
+
 		// Read string bytes
-		nameBuf := make([]byte, nameLen)
-		if _, err := idx.r.ReadAt(nameBuf, offset); err != nil {
+		nameBuf, err := readFull(io.NewSectionReader(idx.r, offset, nameLen), nameLen)
+		if err != nil {
 			return fmt.Errorf("read name %d len %d: %w", i, nameLen, err)
 		}
 		name := string(nameBuf)
-		offset += int64(nameLen)
-		idx.names[i] = name
+		offset += nameLen
+		idx.names = append(idx.names, name)
 	}
 
 	return nil
@@ -126,7 +177,7 @@ func (idx *CIDRIndexFile[S]) readNode(r io.ReaderAt, id int64, b []byte) (trieNo
 	return node, nil
 }
 
-// LookupIP finds the id of the CIDR that contains the given IP.
+// lookupIP finds the id of the CIDR that contains the given IP.
 // Returns "" if no matching CIDR is found.
 func (idx *CIDRIndexFile[S]) lookupIP(ip net.IP) (string, error) {
 	if ip == nil {
@@ -141,9 +192,48 @@ func (idx *CIDRIndexFile[S]) lookupIP(ip net.IP) (string, error) {
 		ip = ip4
 	}
 
+	m, err := idx.lookup(ip, make([]byte, idx.nodeSize))
+	if err != nil {
+		return "", err
+	}
+
+	return idx.name(m.id)
+}
+
+// lookupAddr finds the name of the CIDR that contains the given address, idx.mu must be held.
+func (idx *CIDRIndexFile[S]) lookupAddr(addr netip.Addr, b []byte) (string, error) {
+	m, err := idx.lookupAddrID(addr, b)
+	if err != nil {
+		return "", err
+	}
+
+	return idx.name(m.id)
+}
+
+// lookupAddrID finds the match for the given address, idx.mu must be held.
+func (idx *CIDRIndexFile[S]) lookupAddrID(addr netip.Addr, b []byte) (match[S], error) {
+	if !addr.IsValid() {
+		return match[S]{id: -1, maskLen: -1}, nil
+	}
+
+	addr = addr.Unmap()
+
+	if addr.Is4() {
+		ip := addr.As4()
+
+		return idx.lookup(ip[:], b)
+	}
+
+	ip := addr.As16()
+
+	return idx.lookup(ip[:], b)
+}
+
+// lookup finds the match for the given 4-byte or 16-byte IP, idx.mu must be held.
+func (idx *CIDRIndexFile[S]) lookup(ip []byte, b []byte) (match[S], error) {
 	current := 0
 	bestID := S(-1)
-	bestMaskLen := int8(-1)
+	bestMaskLen := -1
 
 	// Traverse up to 128 bits for IPv6 (or 32 for IPv4).
 	maxBits := 128
@@ -151,47 +241,148 @@ func (idx *CIDRIndexFile[S]) lookupIP(ip net.IP) (string, error) {
 		maxBits = 32 // IPv4.
 	}
 
-	// b := idx.pool.Get().(*[]byte)
-	// defer idx.pool.Put(b)
-
-	b := make([]byte, idx.nodeSize)
+	bits := maxBits
 
 	for i := 0; i < maxBits; i++ {
 		curNode, err := idx.readNode(idx.r, int64(current), b)
 		if err != nil {
-			return "", err
+			return match[S]{}, err
 		}
 
 		// Check if current node has an id and update best match if mask is longer.
-		if curNode.id != -1 && curNode.maskLen > bestMaskLen {
+		if curNode.id != -1 && curNode.bits() > bestMaskLen {
 			bestID = curNode.id
-			bestMaskLen = curNode.maskLen
+			bestMaskLen = curNode.bits()
 		}
 
 		// Get the next bit.
 		bit := (ip[i/8] >> (7 - (i % 8))) & 1
 		childIndex := curNode.children[bit]
 		if childIndex == -1 {
+			bits = i + 1
+
 			break // No further path.
 		}
+
+		if childIndex < 0 || int64(childIndex) >= idx.nodesLen {
+			return match[S]{}, fmt.Errorf("node %d: child %d is out of range", current, childIndex)
+		}
+
 		current = int(childIndex)
 	}
 
 	curNode, err := idx.readNode(idx.r, int64(current), b)
 	if err != nil {
-		return "", err
+		return match[S]{}, err
 	}
 
 	// Check the final node for a better match.
-	if curNode.id != -1 && curNode.maskLen > bestMaskLen {
+	if curNode.id != -1 && curNode.bits() > bestMaskLen {
 		bestID = curNode.id
+		bestMaskLen = curNode.bits()
 	}
 
-	if bestID == -1 {
+	return match[S]{id: bestID, maskLen: bestMaskLen, bits: bits}, nil
+}
+
+// name returns the name for id, or "" if id is -1, idx.mu must be held.
+func (idx *CIDRIndexFile[S]) name(id S) (string, error) {
+	if id == -1 {
 		return "", nil
 	}
 
-	return idx.names[bestID-1], nil
+	if id < 1 || int64(id) > idx.namesLen {
+		return "", fmt.Errorf("invalid name id: %d", id)
+	}
+
+	if idx.names != nil {
+		return idx.names[id-1], nil
+	}
+
+	if name, ok := idx.cache.get(int64(id)); ok {
+		return name, nil
+	}
+
+	var (
+		name string
+		err  error
+	)
+
+	if idx.frontCoded {
+		name, err = idx.frontCodedName(int(id) - 1)
+	} else {
+		name, err = idx.indexedName(int(id) - 1)
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	idx.cache.put(int64(id), name)
+
+	return name, nil
+}
+
+// LookupPrefix finds the name and the prefix of the CIDR that contains the given address.
+// Returns "" and an invalid prefix if no matching CIDR is found.
+func (idx *CIDRIndexFile[S]) LookupPrefix(addr netip.Addr) (string, netip.Prefix, error) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	m, err := idx.lookupAddrID(addr, make([]byte, idx.nodeSize))
+	if err != nil || m.id == -1 {
+		return "", netip.Prefix{}, err
+	}
+
+	name, err := idx.name(m.id)
+	if err != nil {
+		return "", netip.Prefix{}, err
+	}
+
+	return name, matchedPrefix(addr, m.maskLen), nil
+}
+
+// LookupUniform finds the name for the given address and a prefix that contains it,
+// all addresses within the prefix resolve to the same name.
+// Returns an invalid prefix for an invalid address.
+func (idx *CIDRIndexFile[S]) LookupUniform(addr netip.Addr) (string, netip.Prefix, error) {
+	if !addr.IsValid() {
+		return "", netip.Prefix{}, nil
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	m, err := idx.lookupAddrID(addr, make([]byte, idx.nodeSize))
+	if err != nil {
+		return "", netip.Prefix{}, err
+	}
+
+	name, err := idx.name(m.id)
+	if err != nil {
+		return "", netip.Prefix{}, err
+	}
+
+	return name, matchedPrefix(addr, m.bits), nil
+}
+
+// LookupBatch finds names for all ips and stores them in out, out must be at least as long as ips.
+// Addresses are deduplicated and visited in sorted order under a single lock to improve read locality.
+// Failed lookups are reported as "error: ..." names, same as in LookupIP.
+func (idx *CIDRIndexFile[S]) LookupBatch(ips []netip.Addr, out []string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	b := make([]byte, idx.nodeSize)
+
+	lookupBatch(ips, out, func(addr netip.Addr) string {
+		name, err := idx.lookupAddr(addr, b)
+		if err != nil {
+			return "error: " + err.Error()
+		}
+
+		return name
+	})
 }
 
 // SafeLookupIP performs a secure lookup for the given IP within the CIDRIndexFile.
@@ -214,7 +405,7 @@ func (idx *CIDRIndexFile[S]) LookupIP(ip net.IP) string {
 
 // Close releases any resources associated with the CIDRIndexFile, calling Close on the underlying io.Closer if available.
 func (idx *CIDRIndexFile[S]) Close() error {
-	if c, ok := idx.r.(io.Closer); ok {
+	if c, ok := idx.src.r.(io.Closer); ok {
 		return c.Close()
 	}
 
@@ -224,6 +415,66 @@ func (idx *CIDRIndexFile[S]) Close() error {
 // Options represents configuration options for customizing behaviors, such as buffer size for data readers.
 type Options struct {
 	BufferSize int // Default 4096.
+
+	// BlockCache is a number of decompressed blocks to keep in memory for seekable compressed index, default 16.
+	BlockCache int
+
+	// NameCache is a number of recently used names to keep in memory, default 1024.
+	// Names are read on demand for index saved with names offsets or front-coded names, zero disables cache.
+	NameCache int
+
+	// Strict validates the whole index before use, see Validate.
+	// Open reads all nodes and names, Load checks the loaded nodes and that no data follows names.
+	Strict bool
+
+	// Limits of header values and names, index that exceeds them is rejected with ErrLimitExceeded.
+	MaxNodes       int // Default 1<<30.
+	MaxNames       int // Default 1<<26.
+	MaxNameLen     int // Default 1<<20.
+	MaxMetadataLen int // Default 1<<24.
+}
+
+// ErrLimitExceeded is returned when index data exceeds limits of Options.
+var ErrLimitExceeded = errors.New("limit exceeded")
+
+func newOptions(opts []func(o *Options)) Options {
+	o := Options{}
+	o.BufferSize = 4096
+	o.BlockCache = 16
+	o.NameCache = 1024
+	o.MaxNodes = 1 << 30
+	o.MaxNames = 1 << 26
+	o.MaxNameLen = 1 << 20
+	o.MaxMetadataLen = 1 << 24
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// checkHeader checks header values against limits.
+func (o Options) checkHeader(h hd) error {
+	switch {
+	case int64(h.nodesLen) > int64(o.MaxNodes):
+		return fmt.Errorf("%w: %d nodes, max %d", ErrLimitExceeded, h.nodesLen, o.MaxNodes)
+	case int64(h.namesLen) > int64(o.MaxNames):
+		return fmt.Errorf("%w: %d names, max %d", ErrLimitExceeded, h.namesLen, o.MaxNames)
+	case int64(h.metadataLen) > int64(o.MaxMetadataLen):
+		return fmt.Errorf("%w: %d bytes of metadata, max %d", ErrLimitExceeded, h.metadataLen, o.MaxMetadataLen)
+	}
+
+	return nil
+}
+
+// checkNameLen checks length of a name against limit.
+func (o Options) checkNameLen(i int, n int64) error {
+	if n > int64(o.MaxNameLen) {
+		return fmt.Errorf("%w: name %d of %d bytes, max %d", ErrLimitExceeded, i, n, o.MaxNameLen)
+	}
+
+	return nil
 }
 
 // OpenFile opens a file at the specified path and parses it into a SafeIPLookuper
@@ -234,18 +485,23 @@ func OpenFile(fn string, opts ...func(o *Options)) (IPLookuper, error) {
 		return nil, err
 	}
 
-	return Open(f, opts...)
+	l, err := Open(f, opts...)
+	if err != nil {
+		_ = f.Close()
+
+		return nil, err
+	}
+
+	return l, nil
 }
 
 // Open parses a ReaderAt to load an SafeIPLookuper instance for performing IP lookups from a CIDR-based structure.
 // Returns the constructed SafeIPLookuper and an error if any issue occurs during parsing or initialization.
 func Open(r io.ReaderAt, opts ...func(o *Options)) (IPLookuper, error) {
-	o := Options{}
-	o.BufferSize = 4096
+	o := newOptions(opts)
 
-	for _, opt := range opts {
-		opt(&o)
-	}
+	src := &countingReaderAt{r: r}
+	r = src
 
 	if o.BufferSize > 0 {
 		r = newBufReaderAt(r, o.BufferSize)
@@ -258,15 +514,26 @@ func Open(r io.ReaderAt, opts ...func(o *Options)) (IPLookuper, error) {
 		return nil, fmt.Errorf("read header: %w", err)
 	}
 
+	if isCompressedStream(header) {
+		return nil, ErrCompressedStream
+	}
+
 	h := hd{}
 	if err := h.UnmarshalBinary(header); err != nil {
+		if o.Strict {
+			return nil, &ValidationError{Section: "header", Index: -1, Err: err}
+		}
+
 		return nil, fmt.Errorf("unmarshal header: %w", err)
 	}
 
-	if h.metadataLen > 0 {
-		metadataBuf := make([]byte, h.metadataLen)
+	if err := o.checkHeader(h); err != nil {
+		return nil, err
+	}
 
-		if _, err := r.ReadAt(metadataBuf, 20); err != nil {
+	if h.metadataLen > 0 {
+		metadataBuf, err := readFull(io.NewSectionReader(r, 20, int64(h.metadataLen)), int64(h.metadataLen))
+		if err != nil {
 			return nil, fmt.Errorf("read metadata: %w", err)
 		}
 
@@ -275,11 +542,58 @@ func Open(r io.ReaderAt, opts ...func(o *Options)) (IPLookuper, error) {
 		}
 	}
 
+	// End of uncompressed data is only needed for validation.
+	end := int64(-1)
+
+	if size, ok := readerSize(src.r); ok && o.Strict {
+		end = size
+	}
+
+	if h.ver == 2 {
+		// Compressed blocks are read directly and cached decompressed.
+		br, err := newBlockReaderAt(src, h, o.BlockCache)
+		if err != nil {
+			return nil, err
+		}
+
+		r = br
+
+		if o.Strict {
+			if end, err = br.payloadEnd(end); err != nil {
+				return nil, err
+			}
+		}
+	}
+
 	if h.hasLargeNamespace {
-		return newCIDRIndexFile[int32](r, h)
+		return newCIDRIndexFile[int32](r, src, h, o, end)
 	}
 
-	return newCIDRIndexFile[int16](r, h)
+	return newCIDRIndexFile[int16](r, src, h, o, end)
+}
+
+// ReadStats returns the number of reads and bytes read from the underlying storage, bypassing the buffer.
+func (idx *CIDRIndexFile[S]) ReadStats() ReadStats {
+	return ReadStats{
+		Reads: idx.src.reads.Load(),
+		Bytes: idx.src.bytes.Load(),
+	}
+}
+
+// countingReaderAt counts reads from the underlying storage.
+type countingReaderAt struct {
+	r     io.ReaderAt
+	reads atomic.Int64
+	bytes atomic.Int64
+}
+
+func (c *countingReaderAt) ReadAt(b []byte, off int64) (int, error) {
+	n, err := c.r.ReadAt(b, off)
+
+	c.reads.Add(1)
+	c.bytes.Add(int64(n))
+
+	return n, err
 }
 
 // bufReaderAt implements buffering for an io.ReaderAt object.
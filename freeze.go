@@ -0,0 +1,152 @@
+package netrie
+
+import (
+	"io"
+	"net"
+	"net/netip"
+	"slices"
+)
+
+// Index is an immutable minimized index created by Builder.Freeze, it is safe for concurrent lookups.
+type Index[S int16 | int32] struct {
+	idx CIDRIndex[S] // Without idByName map.
+}
+
+// Freeze minimizes the index and returns an immutable copy of it with nodes in depth-first order
+// and without build-time maps. Builder remains usable, but its index is minimized.
+func (b *Builder[S]) Freeze() *Index[S] {
+	b.idx.Minimize()
+
+	fi := &Index[S]{}
+	fi.idx.meta = b.idx.meta
+	fi.idx.total = b.idx.total
+	fi.idx.names = slices.Clone(b.idx.names)
+	fi.idx.nodes = b.idx.preorder()
+	fi.idx.shared = true
+
+	return fi
+}
+
+// preorder returns reachable nodes in depth-first order, so that a node is usually followed by its first child.
+func (idx *CIDRIndex[S]) preorder() []trieNode[S] {
+	newIdx := make([]int32, len(idx.nodes))
+	for i := range newIdx {
+		newIdx[i] = -1
+	}
+
+	n := int32(0)
+
+	var number func(i int32)
+
+	number = func(i int32) {
+		if newIdx[i] != -1 {
+			return
+		}
+
+		newIdx[i] = n
+		n++
+
+		for _, c := range idx.nodes[i].children {
+			if c != -1 {
+				number(c)
+			}
+		}
+	}
+
+	number(0)
+
+	nodes := make([]trieNode[S], n)
+
+	for i, node := range idx.nodes {
+		if newIdx[i] == -1 {
+			continue
+		}
+
+		for bit, c := range node.children {
+			if c != -1 {
+				node.children[bit] = newIdx[c]
+			}
+		}
+
+		nodes[newIdx[i]] = node
+	}
+
+	return nodes
+}
+
+// MemoryUsage returns memory used by nodes and names.
+func (fi *Index[S]) MemoryUsage() MemoryUsage {
+	return MemoryUsage{
+		Nodes: nodesSize(fi.idx.nodes),
+		Names: namesSize(fi.idx.names),
+	}
+}
+
+// Metadata returns a reference to the Metadata of the index.
+func (fi *Index[S]) Metadata() *Metadata {
+	return &fi.idx.meta
+}
+
+// Len returns the number of CIDRs in the index.
+func (fi *Index[S]) Len() int {
+	return fi.idx.total
+}
+
+// LenNames returns the number of different names in the index.
+func (fi *Index[S]) LenNames() int {
+	return len(fi.idx.names)
+}
+
+// LenNodes returns the number of nodes in the index.
+func (fi *Index[S]) LenNodes() int {
+	return len(fi.idx.nodes)
+}
+
+// Lookup finds the name of the CIDR that contains the given IP string.
+// Returns "" if no matching CIDR is found or IP is invalid.
+func (fi *Index[S]) Lookup(ipStr string) string {
+	return fi.idx.Lookup(ipStr)
+}
+
+// LookupIP finds the name of the CIDR that contains the given IP.
+// Returns "" if no matching CIDR is found.
+func (fi *Index[S]) LookupIP(ip net.IP) string {
+	return fi.idx.LookupIP(ip)
+}
+
+// SafeLookupIP finds the name of the CIDR that contains the given IP, error is always nil.
+func (fi *Index[S]) SafeLookupIP(ip net.IP) (string, error) {
+	return fi.idx.LookupIP(ip), nil
+}
+
+// LookupPrefix finds the name and the prefix of the CIDR that contains the given address.
+// Returns "" and an invalid prefix if no matching CIDR is found.
+func (fi *Index[S]) LookupPrefix(addr netip.Addr) (string, netip.Prefix, error) {
+	return fi.idx.LookupPrefix(addr)
+}
+
+// LookupUniform finds the name for the given address and a prefix that contains it,
+// all addresses within the prefix resolve to the same name.
+func (fi *Index[S]) LookupUniform(addr netip.Addr) (string, netip.Prefix, error) {
+	return fi.idx.LookupUniform(addr)
+}
+
+// LookupBatch finds names for all ips and stores them in out, out must be at least as long as ips.
+func (fi *Index[S]) LookupBatch(ips []netip.Addr, out []string) {
+	fi.idx.LookupBatch(ips, out)
+}
+
+// Save writes the index in the same format as CIDRIndex.Save.
+func (fi *Index[S]) Save(w io.Writer, opts ...func(o *SaveOptions)) error {
+	return fi.idx.Save(w, opts...)
+}
+
+// SaveToFile saves the index to a file.
+func (fi *Index[S]) SaveToFile(filename string, opts ...func(o *SaveOptions)) error {
+	return fi.idx.SaveToFile(filename, opts...)
+}
+
+// Close is a no op.
+func (fi *Index[S]) Close() error {
+	return nil
+}
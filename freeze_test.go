@@ -0,0 +1,71 @@
+package netrie_test
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vearutop/netrie"
+)
+
+func TestBuilder_Freeze(t *testing.T) {
+	b := netrie.NewBuilder()
+	b.Metadata().Name = "test"
+
+	for i := 0; i < 2000; i++ {
+		require.NoError(t, b.AddCIDR(fmt.Sprintf("10.%d.%d.0/24", i/256, i%256), fmt.Sprintf("n%d", i%10)))
+	}
+
+	require.NoError(t, b.AddCIDR("2001:db8::/32", "v6"))
+
+	nodes := b.Index().LenNodes()
+	idx := b.Freeze()
+
+	assert.Less(t, idx.LenNodes(), nodes)
+	assert.Equal(t, b.Index().LenNodes(), idx.LenNodes())
+	assert.Equal(t, 2001, idx.Len())
+	assert.Equal(t, 11, idx.LenNames())
+	assert.Equal(t, "test", idx.Metadata().Name)
+
+	m := idx.MemoryUsage()
+	assert.Equal(t, int64(idx.LenNodes()*12), m.Nodes)
+	assert.Positive(t, m.Names)
+	assert.Equal(t, m.Nodes+m.Names, m.Total())
+
+	var l netrie.IPLookuper = idx
+
+	wg := sync.WaitGroup{}
+
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := 0; i < 2000; i++ {
+				assert.Equal(t, fmt.Sprintf("n%d", i%10), l.Lookup(fmt.Sprintf("10.%d.%d.1", i/256, i%256)))
+			}
+
+			assert.Equal(t, "v6", l.Lookup("2001:db8::1"))
+			assert.Equal(t, "", l.Lookup("11.0.0.1"))
+		}()
+	}
+
+	wg.Wait()
+
+	// Builder can still be used, frozen index is not affected.
+	require.NoError(t, b.AddCIDR("11.0.0.0/8", "new"))
+	assert.Equal(t, "new", b.Index().Lookup("11.0.0.1"))
+	assert.Equal(t, "", idx.Lookup("11.0.0.1"))
+
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, idx.Save(buf))
+
+	loaded, err := netrie.Load(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "n7", loaded.Lookup("10.0.7.1"))
+	assert.Equal(t, 11, loaded.LenNames())
+}
@@ -0,0 +1,158 @@
+package netrie
+
+import (
+	"bytes"
+	"net"
+	"net/netip"
+	"testing"
+)
+
+// fuzzSeeds adds a small index saved in all supported formats.
+func fuzzSeeds(f *testing.F) {
+	f.Helper()
+
+	idx := NewCIDRLargeIndex()
+
+	for cidr, name := range map[string]string{
+		"10.0.0.0/8":     "foo",
+		"10.1.0.0/16":    "bar",
+		"192.168.1.0/24": "foo:bar",
+		"2001:db8::/32":  "baz",
+	} {
+		if err := idx.AddCIDR(cidr, name); err != nil {
+			f.Fatal(err)
+		}
+	}
+
+	small := NewCIDRIndex()
+	if err := small.AddCIDR("10.0.0.0/8", "foo"); err != nil {
+		f.Fatal(err)
+	}
+
+	buf := bytes.NewBuffer(nil)
+
+	if err := small.Save(buf); err != nil {
+		f.Fatal(err)
+	}
+
+	f.Add(bytes.Clone(buf.Bytes()))
+
+	for _, opt := range []func(o *SaveOptions){
+		func(o *SaveOptions) { o.Compression = CompressionNone },
+		func(o *SaveOptions) {
+			o.Compression = CompressionNone
+			o.FrontCodedNames = true
+		},
+		func(o *SaveOptions) {
+			o.Compression = CompressionNone
+			o.NamesOffsets = true
+		},
+		func(o *SaveOptions) { o.Compression = CompressionGzip },
+		func(o *SaveOptions) {
+			o.BlockSize = 64
+			o.NamesOffsets = true
+		},
+		func(o *SaveOptions) {
+			o.Compression = CompressionGzip
+			o.BlockSize = 64
+		},
+	} {
+		buf.Reset()
+
+		if err := idx.SaveCompressed(buf, opt); err != nil {
+			f.Fatal(err)
+		}
+
+		f.Add(bytes.Clone(buf.Bytes()))
+	}
+}
+
+var fuzzAddrs = []netip.Addr{
+	netip.MustParseAddr("10.1.2.3"),
+	netip.MustParseAddr("192.168.1.1"),
+	netip.MustParseAddr("2001:db8::1"),
+	netip.MustParseAddr("::"),
+	{},
+}
+
+// lookupAll calls all lookup methods, errors are expected for corrupted data.
+func lookupAll(l IPLookuper) {
+	out := make([]string, len(fuzzAddrs))
+
+	for _, addr := range fuzzAddrs {
+		l.LookupIP(net.IP(addr.AsSlice()))
+		_, _ = l.SafeLookupIP(net.IP(addr.AsSlice()))
+		_, _, _ = l.(PrefixLookuper).LookupPrefix(addr)
+		_, _, _ = l.(UniformLookuper).LookupUniform(addr)
+	}
+
+	LookupBatch(l, fuzzAddrs, out)
+	l.Lookup("10.1.2.3")
+}
+
+func FuzzLoad(f *testing.F) {
+	fuzzSeeds(f)
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		l, err := Load(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+
+		lookupAll(l)
+
+		if _, err := Load(bytes.NewReader(data), func(o *Options) { o.Strict = true }); err == nil {
+			if err := Validate(bytes.NewReader(data)); err != nil {
+				t.Fatalf("strict load succeeded, but validation failed: %v", err)
+			}
+		}
+	})
+}
+
+func FuzzOpen(f *testing.F) {
+	fuzzSeeds(f)
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		l, err := Open(bytes.NewReader(data), func(o *Options) {
+			o.BufferSize = 64
+			o.NameCache = 4
+		})
+		if err != nil {
+			return
+		}
+
+		lookupAll(l)
+
+		if err := Validate(bytes.NewReader(data)); err != nil {
+			return
+		}
+
+		// Valid index is fully readable.
+		for _, addr := range fuzzAddrs {
+			if _, _, err := l.(PrefixLookuper).LookupPrefix(addr); err != nil {
+				t.Fatalf("lookup %s in valid index: %v", addr, err)
+			}
+		}
+	})
+}
+
+func FuzzTrieNode_UnmarshalBinary(f *testing.F) {
+	f.Add([]byte{0, 0, 0, 1, 0xff, 0xff, 0xff, 0xff, 0, 1, 8})
+	f.Add([]byte{0, 0, 0, 1, 0xff, 0xff, 0xff, 0xff, 0, 0, 0, 1, 8})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var n16 trieNode[int16]
+		if err := n16.UnmarshalBinary(data); err == nil {
+			if b, _ := n16.MarshalBinary(); !bytes.Equal(b, data) {
+				t.Fatalf("int16 node round trip: %x != %x", b, data)
+			}
+		}
+
+		var n32 trieNode[int32]
+		if err := n32.UnmarshalBinary(data); err == nil {
+			if b, _ := n32.MarshalBinary(); !bytes.Equal(b, data) {
+				t.Fatalf("int32 node round trip: %x != %x", b, data)
+			}
+		}
+	})
+}
@@ -6,6 +6,7 @@ toolchain go1.24.2
 
 require (
 	github.com/bool64/dev v0.2.43
+	github.com/klauspost/compress v1.18.0
 	github.com/oschwald/maxminddb-golang v1.13.1
 	github.com/stretchr/testify v1.11.1
 	github.com/thcyron/cidrmerge v1.0.2
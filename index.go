@@ -3,6 +3,7 @@ package netrie
 import (
 	"fmt"
 	"net"
+	"net/netip"
 )
 
 // Adder is an interface for adding IP networks or CIDR ranges to a data structure with associated names.
@@ -18,6 +19,23 @@ type Adder interface {
 	Metadata() *Metadata
 }
 
+// ErrReporter is implemented by adders that keep the first error of AddNet, such as CIDRIndex and Builder.
+type ErrReporter interface {
+	Err() error
+}
+
+// AddNet adds an IP network to a with an associated name.
+// Returns the first error of a if it implements ErrReporter.
+func AddNet(a Adder, ipNet *net.IPNet, name string) error {
+	a.AddNet(ipNet, name)
+
+	if er, ok := a.(ErrReporter); ok {
+		return er.Err()
+	}
+
+	return nil
+}
+
 // IPLookuper defines methods to lookup and retrieve information for a given IP or IP string from a CIDR-based structure.
 type IPLookuper interface {
 	SafeLookupIP(ip net.IP) (string, error)
@@ -29,6 +47,37 @@ type IPLookuper interface {
 	Close() error
 }
 
+// BatchIPLookuper is implemented by indexes that can resolve many addresses at once.
+type BatchIPLookuper interface {
+	// LookupBatch finds names for all ips and stores them in out, out must be at least as long as ips.
+	LookupBatch(ips []netip.Addr, out []string)
+}
+
+// PrefixLookuper is implemented by indexes that can report the matched CIDR.
+type PrefixLookuper interface {
+	// LookupPrefix finds the name and the prefix of the CIDR that contains the given address.
+	// Returns "" and an invalid prefix if no matching CIDR is found.
+	LookupPrefix(addr netip.Addr) (string, netip.Prefix, error)
+}
+
+// UniformLookuper is implemented by indexes that can report a range of addresses sharing the lookup result.
+type UniformLookuper interface {
+	// LookupUniform finds the name for the given address and a prefix that contains it,
+	// all addresses within the prefix resolve to the same name.
+	LookupUniform(addr netip.Addr) (string, netip.Prefix, error)
+}
+
+// ReadStats describes reads from the underlying storage of a file-backed index.
+type ReadStats struct {
+	Reads int64 // Number of ReadAt calls.
+	Bytes int64 // Number of bytes read.
+}
+
+// ReadStatsProvider is implemented by file-backed indexes that count reads from the underlying storage.
+type ReadStatsProvider interface {
+	ReadStats() ReadStats
+}
+
 // NewCIDRLargeIndex initializes a new CIDR trie with a root node for up to 2^32 networks.
 func NewCIDRLargeIndex() *CIDRIndex[int32] {
 	return newCIDRIndex[int32]()
@@ -59,17 +108,15 @@ func (idx *CIDRIndex[S]) LenNames() int {
 	return len(idx.idByName)
 }
 
-// AddCIDR adds a CIDR with an associated id to the trie.
-// Returns error if CIDR is invalid or overlaps.
+// AddCIDR adds a CIDR with an associated id to the trie, IPv4-mapped IPv6 CIDR is added as IPv4 CIDR.
+// Returns error if CIDR is invalid or ErrTooManyNames if the name does not fit in the namespace.
 func (idx *CIDRIndex[S]) AddCIDR(cidr string, name string) error {
-	_, ipNet, err := net.ParseCIDR(cidr)
+	p, err := netip.ParsePrefix(cidr)
 	if err != nil {
 		return fmt.Errorf("invalid CIDR (%s): %v", name, cidr)
 	}
 
-	idx.AddNet(ipNet, name)
-
-	return nil
+	return idx.addPrefix(p, name)
 }
 
 // Lookup finds the id of the CIDR that contains the given IP string.
@@ -89,6 +136,13 @@ func (idx *CIDRIndex[S]) SafeLookupIP(ip net.IP) (string, error) {
 	return idx.LookupIP(ip), nil
 }
 
+// matchedPrefix returns the masked prefix of maskLen bits that contains addr.
+func matchedPrefix(addr netip.Addr, maskLen int) netip.Prefix {
+	p, _ := addr.Unmap().Prefix(maskLen)
+
+	return p
+}
+
 // Close is a no op.
 func (idx *CIDRIndex[S]) Close() error {
 	return nil
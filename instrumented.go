@@ -0,0 +1,453 @@
+package netrie
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultLatencyBuckets are upper bounds of lookup latency histogram buckets in seconds.
+var DefaultLatencyBuckets = []float64{1e-7, 2.5e-7, 5e-7, 1e-6, 2.5e-6, 5e-6, 1e-5, 2.5e-5, 5e-5, 1e-4, 1e-3, 1e-2}
+
+// InstrumentOptions configures Instrumented.
+type InstrumentOptions struct {
+	Index    string    // Value of "index" label, default Metadata().Name.
+	Buckets  []float64 // Latency histogram buckets in seconds, default DefaultLatencyBuckets.
+	PerName  bool      // Count hits per name.
+	MaxNames int       // Max number of distinct names counted with PerName, the rest is counted as "_other", default 1000.
+}
+
+// InstrumentedLookuper is an IPLookuper decorator that collects lookup metrics.
+type InstrumentedLookuper struct {
+	IPLookuper
+
+	opts InstrumentOptions
+
+	lookups atomic.Uint64
+	hits    atomic.Uint64
+	errors  atomic.Uint64
+	invalid atomic.Uint64
+	batches atomic.Uint64
+
+	batchNanos atomic.Uint64
+	latency    histogram
+
+	namesMu sync.Mutex
+	names   map[string]*atomic.Uint64
+}
+
+// Instrumented wraps IPLookuper to collect lookup counts, hit/miss ratios, latencies and storage reads.
+// Collected metrics are available with WritePrometheus.
+func Instrumented(l IPLookuper, opts ...func(o *InstrumentOptions)) *InstrumentedLookuper {
+	il := &InstrumentedLookuper{IPLookuper: l}
+
+	il.opts.Buckets = DefaultLatencyBuckets
+	il.opts.MaxNames = 1000
+
+	if m := l.Metadata(); m != nil {
+		il.opts.Index = m.Name
+	}
+
+	for _, opt := range opts {
+		opt(&il.opts)
+	}
+
+	il.latency.buckets = il.opts.Buckets
+	il.latency.counts = make([]atomic.Uint64, len(il.opts.Buckets))
+	il.names = make(map[string]*atomic.Uint64)
+
+	return il
+}
+
+// SafeLookupIP finds the name of the CIDR that contains the given IP and records the lookup.
+func (il *InstrumentedLookuper) SafeLookupIP(ip net.IP) (string, error) {
+	if len(ip) != net.IPv4len && len(ip) != net.IPv6len {
+		il.lookups.Add(1)
+		il.invalid.Add(1)
+
+		return il.IPLookuper.SafeLookupIP(ip)
+	}
+
+	start := time.Now()
+	name, err := il.IPLookuper.SafeLookupIP(ip)
+	il.record(time.Since(start), name, err)
+
+	return name, err
+}
+
+// LookupIP finds the name of the CIDR that contains the given IP and records the lookup.
+// Returns "error: ..." if lookup fails.
+func (il *InstrumentedLookuper) LookupIP(ip net.IP) string {
+	name, err := il.SafeLookupIP(ip)
+	if err != nil {
+		return "error: " + err.Error()
+	}
+
+	return name
+}
+
+// Lookup finds the name of the CIDR that contains the given IP string and records the lookup.
+// Returns "" if no matching CIDR is found or IP is invalid.
+func (il *InstrumentedLookuper) Lookup(ipStr string) string {
+	ip := net.ParseIP(ipStr)
+	if ip == nil {
+		il.lookups.Add(1)
+		il.invalid.Add(1)
+
+		return "" // Invalid IP address.
+	}
+
+	return il.LookupIP(ip)
+}
+
+// LookupBatch finds names for all ips, stores them in out and records the lookups.
+// Lookups of a batch are recorded in latency histogram with average duration per address.
+func (il *InstrumentedLookuper) LookupBatch(ips []netip.Addr, out []string) {
+	start := time.Now()
+	LookupBatch(il.IPLookuper, ips, out)
+	d := time.Since(start)
+	il.batchNanos.Add(uint64(d))
+
+	il.batches.Add(1)
+	il.lookups.Add(uint64(len(ips)))
+
+	valid := 0
+
+	for i, name := range out[:len(ips)] {
+		switch {
+		case !ips[i].IsValid():
+			il.invalid.Add(1)
+		case strings.HasPrefix(name, "error: "):
+			il.errors.Add(1)
+			valid++
+		default:
+			il.hit(name)
+			valid++
+		}
+	}
+
+	if valid > 0 {
+		il.latency.observeN(d/time.Duration(valid), uint64(valid))
+	}
+}
+
+// LookupPrefix finds the name and the prefix of the CIDR that contains the given address and records the lookup.
+// Prefix is only available if the underlying IPLookuper implements PrefixLookuper.
+func (il *InstrumentedLookuper) LookupPrefix(addr netip.Addr) (string, netip.Prefix, error) {
+	pl, ok := il.IPLookuper.(PrefixLookuper)
+	if !ok {
+		name, err := il.SafeLookupIP(addr.AsSlice())
+
+		return name, netip.Prefix{}, err
+	}
+
+	if !addr.IsValid() {
+		il.lookups.Add(1)
+		il.invalid.Add(1)
+
+		return pl.LookupPrefix(addr)
+	}
+
+	start := time.Now()
+	name, prefix, err := pl.LookupPrefix(addr)
+	il.record(time.Since(start), name, err)
+
+	return name, prefix, err
+}
+
+// LookupUniform finds the name for the given address and a prefix of addresses with the same name,
+// and records the lookup. If the underlying IPLookuper does not implement UniformLookuper,
+// prefix only contains the address.
+func (il *InstrumentedLookuper) LookupUniform(addr netip.Addr) (string, netip.Prefix, error) {
+	ul, ok := il.IPLookuper.(UniformLookuper)
+	if !ok {
+		name, err := il.SafeLookupIP(addr.AsSlice())
+		if err != nil || !addr.IsValid() {
+			return name, netip.Prefix{}, err
+		}
+
+		addr = addr.Unmap()
+
+		return name, netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+
+	if !addr.IsValid() {
+		il.lookups.Add(1)
+		il.invalid.Add(1)
+
+		return ul.LookupUniform(addr)
+	}
+
+	start := time.Now()
+	name, prefix, err := ul.LookupUniform(addr)
+	il.record(time.Since(start), name, err)
+
+	return name, prefix, err
+}
+
+// MemoryUsage returns memory used by the underlying IPLookuper if it implements MemoryUsageProvider.
+func (il *InstrumentedLookuper) MemoryUsage() MemoryUsage {
+	if mp, ok := il.IPLookuper.(MemoryUsageProvider); ok {
+		return mp.MemoryUsage()
+	}
+
+	return MemoryUsage{}
+}
+
+// record records a lookup of a valid address.
+func (il *InstrumentedLookuper) record(d time.Duration, name string, err error) {
+	il.latency.observe(d)
+	il.lookups.Add(1)
+
+	if err != nil {
+		il.errors.Add(1)
+	} else {
+		il.hit(name)
+	}
+}
+
+func (il *InstrumentedLookuper) hit(name string) {
+	if name == "" {
+		return
+	}
+
+	il.hits.Add(1)
+
+	if !il.opts.PerName {
+		return
+	}
+
+	il.namesMu.Lock()
+	c, ok := il.names[name]
+
+	if !ok {
+		if len(il.names) >= il.opts.MaxNames {
+			name = "_other"
+			c = il.names[name]
+		}
+
+		if c == nil {
+			c = &atomic.Uint64{}
+			il.names[name] = c
+		}
+	}
+	il.namesMu.Unlock()
+
+	c.Add(1)
+}
+
+// LookupStats is a snapshot of lookup counters.
+type LookupStats struct {
+	Lookups uint64
+	Hits    uint64
+	Misses  uint64
+	Errors  uint64
+	Invalid uint64 // Lookups of invalid addresses, they are not counted as misses.
+	Batches uint64
+}
+
+// HitRatio returns the share of lookups that found a name.
+func (s LookupStats) HitRatio() float64 {
+	if s.Lookups == 0 {
+		return 0
+	}
+
+	return float64(s.Hits) / float64(s.Lookups)
+}
+
+// Stats returns a snapshot of lookup counters.
+func (il *InstrumentedLookuper) Stats() LookupStats {
+	s := LookupStats{
+		Lookups: il.lookups.Load(),
+		Hits:    il.hits.Load(),
+		Errors:  il.errors.Load(),
+		Invalid: il.invalid.Load(),
+		Batches: il.batches.Load(),
+	}
+
+	if s.Lookups > s.Hits+s.Errors+s.Invalid {
+		s.Misses = s.Lookups - s.Hits - s.Errors - s.Invalid
+	}
+
+	return s
+}
+
+// NameHits returns the number of hits per name, collected with InstrumentOptions.PerName.
+func (il *InstrumentedLookuper) NameHits() map[string]uint64 {
+	il.namesMu.Lock()
+	defer il.namesMu.Unlock()
+
+	res := make(map[string]uint64, len(il.names))
+	for name, c := range il.names {
+		res[name] = c.Load()
+	}
+
+	return res
+}
+
+// WritePrometheus writes metrics in Prometheus text exposition format.
+func (il *InstrumentedLookuper) WritePrometheus(w io.Writer) error {
+	return WritePrometheus(w, il)
+}
+
+// WritePrometheus writes metrics of multiple instrumented lookupers in Prometheus text exposition format.
+func WritePrometheus(w io.Writer, ls ...*InstrumentedLookuper) error {
+	bw := bufio.NewWriter(w)
+
+	family := func(name, typ, help string, each func(il *InstrumentedLookuper, lbl string)) {
+		_, _ = fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+
+		for _, il := range ls {
+			each(il, `index="`+escapeLabel(il.opts.Index)+`"`)
+		}
+	}
+
+	counter := func(name, help string, value func(s LookupStats) uint64) {
+		family(name, "counter", help, func(il *InstrumentedLookuper, lbl string) {
+			_, _ = fmt.Fprintf(bw, "%s{%s} %d\n", name, lbl, value(il.Stats()))
+		})
+	}
+
+	counter("netrie_lookups_total", "Number of lookups.", func(s LookupStats) uint64 { return s.Lookups })
+	counter("netrie_lookup_hits_total", "Number of lookups that found a name.", func(s LookupStats) uint64 { return s.Hits })
+	counter("netrie_lookup_misses_total", "Number of lookups that did not find a name.", func(s LookupStats) uint64 { return s.Misses })
+	counter("netrie_lookup_errors_total", "Number of failed lookups.", func(s LookupStats) uint64 { return s.Errors })
+	counter("netrie_lookup_invalid_total", "Number of lookups of invalid addresses.", func(s LookupStats) uint64 { return s.Invalid })
+	counter("netrie_lookup_batches_total", "Number of batch lookups.", func(s LookupStats) uint64 { return s.Batches })
+
+	family("netrie_lookup_batch_duration_seconds_total", "counter", "Total duration of batch lookups.",
+		func(il *InstrumentedLookuper, lbl string) {
+			_, _ = fmt.Fprintf(bw, "%s{%s} %s\n", "netrie_lookup_batch_duration_seconds_total", lbl,
+				formatFloat(float64(il.batchNanos.Load())/1e9))
+		})
+
+	family("netrie_lookup_duration_seconds", "histogram", "Latency of lookups, average per address for batches.",
+		func(il *InstrumentedLookuper, lbl string) {
+			il.latency.write(bw, "netrie_lookup_duration_seconds", lbl)
+		})
+
+	family("netrie_lookup_name_hits_total", "counter", "Number of hits per name.",
+		func(il *InstrumentedLookuper, lbl string) {
+			hits := il.NameHits()
+			names := make([]string, 0, len(hits))
+
+			for name := range hits {
+				names = append(names, name)
+			}
+
+			sort.Strings(names)
+
+			for _, name := range names {
+				_, _ = fmt.Fprintf(bw, "netrie_lookup_name_hits_total{%s,name=\"%s\"} %d\n", lbl, escapeLabel(name), hits[name])
+			}
+		})
+
+	storage := func(name, help string, value func(s ReadStats) int64) {
+		family(name, "counter", help, func(il *InstrumentedLookuper, lbl string) {
+			if rs, ok := il.IPLookuper.(ReadStatsProvider); ok {
+				_, _ = fmt.Fprintf(bw, "%s{%s} %d\n", name, lbl, value(rs.ReadStats()))
+			}
+		})
+	}
+
+	storage("netrie_storage_reads_total", "Number of reads from underlying storage of file-backed index.",
+		func(s ReadStats) int64 { return s.Reads })
+	storage("netrie_storage_read_bytes_total", "Number of bytes read from underlying storage of file-backed index.",
+		func(s ReadStats) int64 { return s.Bytes })
+
+	family("netrie_index_info", "gauge", "Index metadata.", func(il *InstrumentedLookuper, lbl string) {
+		m := il.Metadata()
+		if m == nil {
+			m = &Metadata{}
+		}
+
+		buildDate := ""
+		if !m.BuildDate.IsZero() {
+			buildDate = m.BuildDate.UTC().Format(time.RFC3339)
+		}
+
+		_, _ = fmt.Fprintf(bw, "netrie_index_info{%s,name=\"%s\",description=\"%s\",build_date=\"%s\"} 1\n",
+			lbl, escapeLabel(m.Name), escapeLabel(m.Description), buildDate)
+	})
+
+	family("netrie_index_build_timestamp_seconds", "gauge", "Index build time as Unix timestamp.",
+		func(il *InstrumentedLookuper, lbl string) {
+			if m := il.Metadata(); m != nil && !m.BuildDate.IsZero() {
+				_, _ = fmt.Fprintf(bw, "netrie_index_build_timestamp_seconds{%s} %d\n", lbl, m.BuildDate.Unix())
+			}
+		})
+
+	family("netrie_index_cidrs", "gauge", "Number of CIDRs in the index.", func(il *InstrumentedLookuper, lbl string) {
+		_, _ = fmt.Fprintf(bw, "netrie_index_cidrs{%s} %d\n", lbl, il.Len())
+	})
+
+	family("netrie_index_names", "gauge", "Number of names in the index.", func(il *InstrumentedLookuper, lbl string) {
+		_, _ = fmt.Fprintf(bw, "netrie_index_names{%s} %d\n", lbl, il.LenNames())
+	})
+
+	return bw.Flush()
+}
+
+// histogram is a lock-free latency histogram.
+type histogram struct {
+	buckets []float64
+	counts  []atomic.Uint64 // Non-cumulative counts per bucket.
+	count   atomic.Uint64
+	nanos   atomic.Uint64
+}
+
+func (h *histogram) observe(d time.Duration) {
+	h.observeN(d, 1)
+}
+
+// observeN records n observations of duration d.
+func (h *histogram) observeN(d time.Duration, n uint64) {
+	h.count.Add(n)
+	h.nanos.Add(uint64(d) * n)
+
+	v := d.Seconds()
+
+	for i, b := range h.buckets {
+		if v <= b {
+			h.counts[i].Add(n)
+
+			break
+		}
+	}
+}
+
+func (h *histogram) write(w io.Writer, name, lbl string) {
+	var cumulative uint64
+
+	for i, b := range h.buckets {
+		cumulative += h.counts[i].Load()
+		_, _ = fmt.Fprintf(w, "%s_bucket{%s,le=\"%s\"} %d\n", name, lbl, formatFloat(b), cumulative)
+	}
+
+	_, _ = fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, lbl, h.count.Load())
+	_, _ = fmt.Fprintf(w, "%s_sum{%s} %s\n", name, lbl, formatFloat(float64(h.nanos.Load())/1e9))
+	_, _ = fmt.Fprintf(w, "%s_count{%s} %d\n", name, lbl, h.count.Load())
+}
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, 1) {
+		return "+Inf"
+	}
+
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+var labelReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelReplacer.Replace(s)
+}
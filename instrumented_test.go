@@ -0,0 +1,99 @@
+package netrie_test
+
+import (
+	"bytes"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vearutop/netrie"
+)
+
+func TestInstrumented(t *testing.T) {
+	trf, err := netrie.OpenFile("testdata/cities.bin")
+	require.NoError(t, err)
+
+	il := netrie.Instrumented(trf, func(o *netrie.InstrumentOptions) {
+		o.Index = "cities"
+		o.PerName = true
+		o.MaxNames = 2
+	})
+	defer il.Close()
+
+	assert.Equal(t, "GB:Boxford", il.Lookup("2.125.160.217"))
+	assert.Equal(t, "GB:London", il.Lookup("81.2.69.145"))
+	assert.Equal(t, "GB:London", il.Lookup("81.2.69.145"))
+	assert.Equal(t, "", il.Lookup("143.198.196.44"))
+	assert.Equal(t, "", il.Lookup("invalid"))
+
+	out := make([]string, 3)
+	il.LookupBatch([]netip.Addr{netip.MustParseAddr("2001:480:10::1"), netip.MustParseAddr("10.0.0.1"), {}}, out)
+	assert.Equal(t, []string{"US:San Diego", "", ""}, out)
+
+	name, prefix, err := il.LookupPrefix(netip.MustParseAddr("81.2.69.145"))
+	require.NoError(t, err)
+	assert.Equal(t, "GB:London", name)
+	assert.True(t, prefix.IsValid())
+
+	// Invalid addresses are counted separately from misses.
+	assert.Equal(t, netrie.LookupStats{Lookups: 9, Hits: 5, Misses: 2, Invalid: 2, Batches: 1}, il.Stats())
+	assert.InDelta(t, 5.0/9, il.Stats().HitRatio(), 1e-9)
+	assert.Equal(t, map[string]uint64{"GB:Boxford": 1, "GB:London": 3, "_other": 1}, il.NameHits())
+
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, il.WritePrometheus(buf))
+
+	m := buf.String()
+	assert.Contains(t, m, "# TYPE netrie_lookups_total counter\nnetrie_lookups_total{index=\"cities\"} 9\n")
+	assert.Contains(t, m, "netrie_lookup_hits_total{index=\"cities\"} 5\n")
+	assert.Contains(t, m, "netrie_lookup_misses_total{index=\"cities\"} 2\n")
+	assert.Contains(t, m, "netrie_lookup_invalid_total{index=\"cities\"} 2\n")
+	// Single lookups and valid addresses of batch.
+	assert.Contains(t, m, "netrie_lookup_duration_seconds_bucket{index=\"cities\",le=\"+Inf\"} 7\n")
+	assert.Contains(t, m, "netrie_lookup_duration_seconds_count{index=\"cities\"} 7\n")
+	assert.Contains(t, m, "netrie_lookup_name_hits_total{index=\"cities\",name=\"GB:London\"} 3\n")
+	assert.Contains(t, m, "netrie_storage_reads_total{index=\"cities\"} ")
+	assert.Contains(t, m, "netrie_index_build_timestamp_seconds{index=\"cities\"} 1755020941\n")
+	assert.Contains(t, m, "netrie_index_cidrs{index=\"cities\"} 250\n")
+	assert.Contains(t, m, "netrie_index_info{index=\"cities\",name=\"\",description=\"\",build_date=\"2025-08-12T17:49:01Z\"} 1\n")
+}
+
+func TestInstrumented_forward(t *testing.T) {
+	idx := netrie.NewCIDRIndex()
+	require.NoError(t, idx.AddCIDR("10.0.0.0/8", "foo"))
+
+	il := netrie.Instrumented(idx)
+
+	mp, ok := netrie.IPLookuper(il).(netrie.MemoryUsageProvider)
+	require.True(t, ok)
+	assert.Equal(t, idx.MemoryUsage(), mp.MemoryUsage())
+
+	ul, ok := netrie.IPLookuper(il).(netrie.UniformLookuper)
+	require.True(t, ok)
+
+	_, want, err := idx.LookupUniform(netip.MustParseAddr("10.1.2.3"))
+	require.NoError(t, err)
+
+	name, prefix, err := ul.LookupUniform(netip.MustParseAddr("10.1.2.3"))
+	require.NoError(t, err)
+	assert.Equal(t, "foo", name)
+	assert.Equal(t, want, prefix)
+
+	_, _, err = ul.LookupUniform(netip.Addr{})
+	require.NoError(t, err)
+
+	assert.Equal(t, netrie.LookupStats{Lookups: 2, Hits: 1, Invalid: 1}, il.Stats())
+
+	// Underlying lookuper without optional interfaces.
+	il = netrie.Instrumented(struct{ netrie.IPLookuper }{idx})
+
+	name, prefix, err = il.LookupUniform(netip.MustParseAddr("10.1.2.3"))
+	require.NoError(t, err)
+	assert.Equal(t, "foo", name)
+	assert.Equal(t, "10.1.2.3/32", prefix.String())
+	assert.Equal(t, netrie.MemoryUsage{}, il.MemoryUsage())
+
+	assert.Equal(t, "", il.LookupIP(nil))
+	assert.Equal(t, netrie.LookupStats{Lookups: 2, Hits: 1, Invalid: 1}, il.Stats())
+}
@@ -0,0 +1,232 @@
+// Package bgp provides importer of origin ASNs from BGP routing tables.
+//
+// Supported sources are MRT TABLE_DUMP_V2 RIB dumps (as published by RouteViews and RIPE RIS)
+// and plain text "prefix origin-as" tables (as exported by pyasn or bird).
+package bgp
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/vearutop/netrie"
+)
+
+// Options defines configuration of loaders.
+type Options struct {
+	// ASNames is an optional path to AS names file, with lines like "13335 CLOUDFLARENET, US"
+	// or a JSON object like {"13335": "CLOUDFLARENET, US"}.
+	ASNames string
+
+	// MakeName makes a name for origin ASN and AS org name (empty if unknown), default "AS<num> <org>".
+	// Prefixes with empty names are skipped.
+	MakeName func(asn uint32, org string) string
+}
+
+// ASNOrg makes names like mmdb.ASNOrg: "AS<number> <organization>" or "AS<number>" if organization is unknown.
+func ASNOrg(asn uint32, org string) string {
+	res := "AS" + strconv.FormatUint(uint64(asn), 10)
+
+	if org != "" {
+		res += " " + org
+	}
+
+	return res
+}
+
+func newOptions(opts []func(o *Options)) (*Options, map[uint32]string, error) {
+	o := &Options{}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if o.MakeName == nil {
+		o.MakeName = ASNOrg
+	}
+
+	if o.ASNames == "" {
+		return o, nil, nil
+	}
+
+	names, err := LoadASNames(o.ASNames)
+	if err != nil {
+		return nil, nil, fmt.Errorf("load AS names: %w", err)
+	}
+
+	return o, names, nil
+}
+
+// LoadText reads "prefix origin-as" lines and adds prefixes to the trie.
+// Origin may be prefixed with "AS", for AS sets ("{1,2}") the first ASN is used.
+// Empty lines and lines starting with '#' or ';' are skipped.
+//
+// Full routing table has more origin ASNs than netrie.NewCIDRIndex can name, use netrie.NewCIDRLargeIndex,
+// otherwise netrie.ErrTooManyNames is returned.
+func LoadText(tr netrie.Adder, path string, options ...func(o *Options)) error {
+	o, names, err := newOptions(options)
+	if err != nil {
+		return err
+	}
+
+	f, err := openFile(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	setMetadata(tr)
+
+	s := bufio.NewScanner(f)
+	line := 0
+
+	for s.Scan() {
+		line++
+
+		l := strings.TrimSpace(s.Text())
+		if l == "" || l[0] == '#' || l[0] == ';' {
+			continue
+		}
+
+		fields := strings.Fields(l)
+		if len(fields) < 2 {
+			return fmt.Errorf("line %d: origin AS expected", line)
+		}
+
+		_, n, err := net.ParseCIDR(fields[0])
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+
+		asn, err := parseASN(fields[1])
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+
+		if name := o.MakeName(asn, names[asn]); name != "" {
+			if err := netrie.AddNet(tr, n, name); err != nil {
+				return fmt.Errorf("line %d: %w", line, err)
+			}
+		}
+	}
+
+	return s.Err()
+}
+
+// parseASN parses "13335", "AS13335" or "{13335,209242}".
+func parseASN(s string) (uint32, error) {
+	v := strings.TrimPrefix(strings.TrimPrefix(s, "AS"), "as")
+	v = strings.TrimPrefix(v, "{")
+
+	if i := strings.IndexAny(v, ",}"); i != -1 {
+		v = v[:i]
+	}
+
+	asn, err := strconv.ParseUint(v, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid origin AS %q", s)
+	}
+
+	return uint32(asn), nil
+}
+
+// LoadASNames reads AS names from a text file with "<asn> <name>" lines or from a JSON object.
+func LoadASNames(path string) (map[uint32]string, error) {
+	f, err := openFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	br := bufio.NewReader(f)
+
+	if b, err := br.Peek(1); err == nil && b[0] == '{' {
+		var m map[string]string
+
+		if err := json.NewDecoder(br).Decode(&m); err != nil {
+			return nil, err
+		}
+
+		res := make(map[uint32]string, len(m))
+
+		for k, v := range m {
+			asn, err := parseASN(k)
+			if err != nil {
+				return nil, err
+			}
+
+			res[asn] = v
+		}
+
+		return res, nil
+	}
+
+	res := make(map[uint32]string)
+	s := bufio.NewScanner(br)
+
+	for s.Scan() {
+		l := strings.TrimSpace(s.Text())
+		if l == "" || l[0] == '#' {
+			continue
+		}
+
+		k, v, _ := strings.Cut(l, " ")
+
+		asn, err := parseASN(k)
+		if err != nil {
+			continue // Skip headers and malformed lines.
+		}
+
+		res[asn] = strings.TrimSpace(v)
+	}
+
+	return res, s.Err()
+}
+
+func setMetadata(tr netrie.Adder) {
+	if meta := tr.Metadata(); meta.Name == "" {
+		meta.Name = "BGP origin ASN"
+	}
+}
+
+// openFile opens a file and transparently decompresses gzip or bzip2 content.
+func openFile(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReader(f)
+	magic, _ := br.Peek(3)
+
+	var r io.Reader = br
+
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			_ = f.Close()
+
+			return nil, err
+		}
+
+		r = gr
+	case bytes.Equal(magic, []byte("BZh")):
+		r = bzip2.NewReader(br)
+	}
+
+	return readCloser{Reader: r, Closer: f}, nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,75 @@
+package bgp_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vearutop/netrie"
+	"github.com/vearutop/netrie/lists/bgp"
+)
+
+func TestLoadMRT(t *testing.T) {
+	for _, fn := range []string{"testdata/rib.mrt", "testdata/rib.mrt.bz2"} {
+		t.Run(fn, func(t *testing.T) {
+			tr := netrie.NewCIDRIndex()
+
+			require.NoError(t, bgp.LoadMRT(tr, fn))
+
+			assert.Equal(t, 8, tr.Len())
+			assert.Equal(t, "AS13335", tr.Lookup("1.0.0.1"))
+			assert.Equal(t, "AS15169", tr.Lookup("8.8.8.8"))
+			assert.Equal(t, "AS64512", tr.Lookup("10.2.0.1"))
+			assert.Equal(t, "AS64513", tr.Lookup("10.1.0.1"))
+			assert.Equal(t, "AS64500", tr.Lookup("192.0.2.1"))    // Tie of peers, lower ASN wins.
+			assert.Equal(t, "AS64600", tr.Lookup("198.51.100.1")) // AS_SET.
+			assert.Equal(t, "", tr.Lookup("203.0.113.1"))         // No AS_PATH.
+			assert.Equal(t, "AS13335", tr.Lookup("2606:4700::1111"))
+			assert.Equal(t, "AS15169", tr.Lookup("2001:4860:4860::8888"))
+
+			assert.Equal(t, time.Date(2024, 1, 16, 0, 0, 0, 0, time.UTC), tr.Metadata().BuildDate)
+			assert.Equal(t, "BGP origin ASN", tr.Metadata().Name)
+		})
+	}
+}
+
+func TestLoadText(t *testing.T) {
+	for _, names := range []string{"testdata/asnames.txt", "testdata/asnames.json"} {
+		tr := netrie.NewCIDRIndex()
+
+		require.NoError(t, bgp.LoadText(tr, "testdata/ipasn.dat", func(o *bgp.Options) {
+			o.ASNames = names
+		}))
+
+		assert.Equal(t, 7, tr.Len())
+		assert.Equal(t, "AS13335 CLOUDFLARENET, US", tr.Lookup("1.0.0.1"))
+		assert.Equal(t, "AS15169 GOOGLE, US", tr.Lookup("8.8.8.8"))
+		assert.Equal(t, "AS64513", tr.Lookup("10.1.0.1"))
+		assert.Equal(t, "AS64600", tr.Lookup("198.51.100.1"))
+		assert.Equal(t, "AS13335 CLOUDFLARENET, US", tr.Lookup("2606:4700::1111"))
+	}
+}
+
+func TestLoadMRT_makeName(t *testing.T) {
+	tr := netrie.NewCIDRIndex()
+
+	require.NoError(t, bgp.LoadMRT(tr, "testdata/rib.mrt", func(o *bgp.Options) {
+		o.ASNames = "testdata/asnames.txt"
+		o.MakeName = func(asn uint32, org string) string {
+			return org
+		}
+	}))
+
+	assert.Equal(t, 4, tr.Len())
+	assert.Equal(t, "CLOUDFLARENET, US", tr.Lookup("1.0.0.1"))
+	assert.Equal(t, "", tr.Lookup("10.1.0.1"))
+}
+
+func TestLoadMRT_errors(t *testing.T) {
+	tr := netrie.NewCIDRIndex()
+
+	require.Error(t, bgp.LoadMRT(tr, "testdata/missing.mrt"))
+	require.EqualError(t, bgp.LoadMRT(tr, "testdata/ipasn.dat"), "record 0: record too long: 858926404")
+	require.EqualError(t, bgp.LoadText(tr, "testdata/asnames.txt"), "line 2: invalid CIDR address: AS13335")
+}
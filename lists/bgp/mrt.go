@@ -0,0 +1,272 @@
+package bgp
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/vearutop/netrie"
+)
+
+// MRT constants, see RFC 6396.
+const (
+	mrtTableDumpV2 = 13
+
+	subtypePeerIndexTable = 1
+	subtypeRIBIPv4Unicast = 2
+	subtypeRIBIPv6Unicast = 4
+
+	attrASPath      = 2
+	attrFlagExtLen  = 0x10
+	asPathSet       = 1
+	asPathSequence  = 2
+	maxMRTRecordLen = 16 << 20
+)
+
+// LoadMRT reads MRT TABLE_DUMP_V2 RIB dump (optionally gzip or bzip2 compressed) and adds
+// IPv4 and IPv6 unicast prefixes to the trie, named by origin ASN.
+//
+// Origin is the last ASN of AS_PATH, for AS_SET the first member of the set is used.
+// When peers disagree on origin, the one reported by most peers wins.
+// Metadata build date is set to the timestamp of the dump.
+//
+// Full routing table has more origin ASNs than netrie.NewCIDRIndex can name, use netrie.NewCIDRLargeIndex,
+// otherwise netrie.ErrTooManyNames is returned.
+func LoadMRT(tr netrie.Adder, path string, options ...func(o *Options)) error {
+	o, names, err := newOptions(options)
+	if err != nil {
+		return err
+	}
+
+	f, err := openFile(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	setMetadata(tr)
+
+	r := bufio.NewReader(f)
+	hdr := make([]byte, 12)
+
+	var (
+		body    []byte
+		dumpAt  time.Time
+		records int
+	)
+
+	for {
+		if _, err := io.ReadFull(r, hdr); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+
+			return fmt.Errorf("record %d: read header: %w", records, err)
+		}
+
+		ts := binary.BigEndian.Uint32(hdr[0:4])
+		typ := binary.BigEndian.Uint16(hdr[4:6])
+		subtype := binary.BigEndian.Uint16(hdr[6:8])
+		l := binary.BigEndian.Uint32(hdr[8:12])
+
+		if l > maxMRTRecordLen {
+			return fmt.Errorf("record %d: record too long: %d", records, l)
+		}
+
+		if cap(body) < int(l) {
+			body = make([]byte, l)
+		}
+
+		body = body[:l]
+
+		if _, err := io.ReadFull(r, body); err != nil {
+			return fmt.Errorf("record %d: read body: %w", records, err)
+		}
+
+		records++
+
+		if typ != mrtTableDumpV2 {
+			continue
+		}
+
+		switch subtype {
+		case subtypePeerIndexTable:
+			dumpAt = time.Unix(int64(ts), 0).UTC()
+		case subtypeRIBIPv4Unicast, subtypeRIBIPv6Unicast:
+			size := net.IPv4len
+			if subtype == subtypeRIBIPv6Unicast {
+				size = net.IPv6len
+			}
+
+			n, asn, err := parseRIB(body, size)
+			if err != nil {
+				return fmt.Errorf("record %d: %w", records, err)
+			}
+
+			if n == nil {
+				continue // No origin.
+			}
+
+			if name := o.MakeName(asn, names[asn]); name != "" {
+				if err := netrie.AddNet(tr, n, name); err != nil {
+					return fmt.Errorf("record %d: %w", records, err)
+				}
+			}
+		}
+	}
+
+	if !dumpAt.IsZero() {
+		tr.Metadata().BuildDate = dumpAt
+	}
+
+	return nil
+}
+
+var errTruncated = errors.New("truncated RIB record")
+
+// parseRIB parses RIB_IPV4_UNICAST or RIB_IPV6_UNICAST record and returns the prefix and its origin ASN.
+// Nil prefix is returned if none of the entries has an origin.
+func parseRIB(b []byte, size int) (*net.IPNet, uint32, error) {
+	// Sequence number.
+	if len(b) < 5 {
+		return nil, 0, errTruncated
+	}
+
+	bits := int(b[4])
+	b = b[5:]
+
+	if bits > size*8 {
+		return nil, 0, fmt.Errorf("invalid prefix length: %d", bits)
+	}
+
+	pl := (bits + 7) / 8
+	if len(b) < pl+2 {
+		return nil, 0, errTruncated
+	}
+
+	ip := make(net.IP, size)
+	copy(ip, b[:pl])
+
+	n := &net.IPNet{IP: ip.Mask(net.CIDRMask(bits, size*8)), Mask: net.CIDRMask(bits, size*8)}
+
+	cnt := int(binary.BigEndian.Uint16(b[pl:]))
+	b = b[pl+2:]
+
+	votes := make(map[uint32]int, 1)
+
+	var (
+		best      uint32
+		bestVotes int
+	)
+
+	for i := 0; i < cnt; i++ {
+		// Peer index (2), originated time (4), attribute length (2).
+		if len(b) < 8 {
+			return nil, 0, errTruncated
+		}
+
+		al := int(binary.BigEndian.Uint16(b[6:8]))
+		b = b[8:]
+
+		if len(b) < al {
+			return nil, 0, errTruncated
+		}
+
+		asn, ok, err := origin(b[:al])
+		if err != nil {
+			return nil, 0, err
+		}
+
+		b = b[al:]
+
+		if !ok {
+			continue
+		}
+
+		votes[asn]++
+
+		if v := votes[asn]; v > bestVotes || (v == bestVotes && asn < best) {
+			best, bestVotes = asn, v
+		}
+	}
+
+	if bestVotes == 0 {
+		return nil, 0, nil
+	}
+
+	return n, best, nil
+}
+
+// origin finds origin ASN in BGP path attributes, ASNs are 4 bytes long in TABLE_DUMP_V2.
+func origin(b []byte) (uint32, bool, error) {
+	for len(b) > 0 {
+		if len(b) < 3 {
+			return 0, false, errTruncated
+		}
+
+		flags, typ := b[0], b[1]
+
+		var l int
+
+		if flags&attrFlagExtLen != 0 {
+			if len(b) < 4 {
+				return 0, false, errTruncated
+			}
+
+			l = int(binary.BigEndian.Uint16(b[2:4]))
+			b = b[4:]
+		} else {
+			l = int(b[2])
+			b = b[3:]
+		}
+
+		if len(b) < l {
+			return 0, false, errTruncated
+		}
+
+		if typ == attrASPath {
+			return pathOrigin(b[:l])
+		}
+
+		b = b[l:]
+	}
+
+	return 0, false, nil
+}
+
+func pathOrigin(b []byte) (uint32, bool, error) {
+	var (
+		asn uint32
+		ok  bool
+	)
+
+	for len(b) > 0 {
+		if len(b) < 2 {
+			return 0, false, errTruncated
+		}
+
+		segType, cnt := b[0], int(b[1])
+		b = b[2:]
+
+		if len(b) < cnt*4 {
+			return 0, false, errTruncated
+		}
+
+		if cnt > 0 {
+			switch segType {
+			case asPathSequence:
+				asn, ok = binary.BigEndian.Uint32(b[(cnt-1)*4:]), true
+			case asPathSet:
+				asn, ok = binary.BigEndian.Uint32(b), true
+			}
+		}
+
+		b = b[cnt*4:]
+	}
+
+	return asn, ok, nil
+}
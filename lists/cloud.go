@@ -0,0 +1,359 @@
+package lists
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/vearutop/netrie"
+)
+
+// CloudRange is an IP range published by a cloud provider.
+type CloudRange struct {
+	Provider string // Provider id, e.g. "aws", "gcp", "azure", "oracle", "cloudflare", "fastly".
+	Region   string // Provider region, e.g. "us-east-1", can be empty.
+	Service  string // Provider service, e.g. "EC2", can be empty.
+	Prefix   *net.IPNet
+
+	// specificity defines which range wins if a prefix is listed multiple times.
+	specificity int
+}
+
+// CloudOptions configures cloud provider loaders.
+type CloudOptions struct {
+	// Template of a name with {provider}, {region} and {service} placeholders, e.g. "{provider}:{service}".
+	// By default, non-empty fields are joined with ":", e.g. "aws:us-east-1:EC2".
+	Template string
+
+	// MakeName builds a name for a range, overrides Template.
+	// Empty name skips the range.
+	MakeName func(r CloudRange) string
+}
+
+func (o CloudOptions) makeName() func(r CloudRange) string {
+	if o.MakeName != nil {
+		return o.MakeName
+	}
+
+	if o.Template != "" {
+		return func(r CloudRange) string {
+			return strings.NewReplacer(
+				"{provider}", r.Provider,
+				"{region}", r.Region,
+				"{service}", r.Service,
+			).Replace(o.Template)
+		}
+	}
+
+	return func(r CloudRange) string {
+		name := r.Provider
+
+		for _, s := range []string{r.Region, r.Service} {
+			if s != "" {
+				name += ":" + s
+			}
+		}
+
+		return name
+	}
+}
+
+// LoadAWS loads AWS ip-ranges.json from a file or URL (https://ip-ranges.amazonaws.com/ip-ranges.json).
+//
+// Prefixes listed under generic "AMAZON" service and under a specific service are named by the specific service.
+func LoadAWS(u string, tr netrie.Adder, options ...func(o *CloudOptions)) error {
+	return LoadAWSContext(context.Background(), Source{URL: u}, tr, options...)
+}
+
+// LoadAWSContext is LoadAWS with context and configurable Source.
+func LoadAWSContext(ctx context.Context, src Source, tr netrie.Adder, options ...func(o *CloudOptions)) error {
+	return loadCloud(ctx, src, tr, options, func(r io.Reader, add func(cr CloudRange, prefix string) error) error {
+		type prefix struct {
+			IPPrefix   string `json:"ip_prefix"`
+			IPv6Prefix string `json:"ipv6_prefix"`
+			Region     string `json:"region"`
+			Service    string `json:"service"`
+		}
+
+		var doc struct {
+			CreateDate   string   `json:"createDate"`
+			Prefixes     []prefix `json:"prefixes"`
+			IPv6Prefixes []prefix `json:"ipv6_prefixes"`
+		}
+
+		if err := json.NewDecoder(r).Decode(&doc); err != nil {
+			return err
+		}
+
+		setBuildDate(tr, "2006-01-02-15-04-05", doc.CreateDate)
+
+		for _, p := range append(doc.Prefixes, doc.IPv6Prefixes...) {
+			cr := CloudRange{Provider: "aws", Region: p.Region, Service: p.Service, specificity: 1}
+			if p.Service != "AMAZON" {
+				cr.specificity++
+			}
+
+			if err := add(cr, p.IPPrefix+p.IPv6Prefix); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// LoadGCP loads Google Cloud cloud.json (https://www.gstatic.com/ipranges/cloud.json)
+// or goog.json from a file or URL, scope is used as a region.
+func LoadGCP(u string, tr netrie.Adder, options ...func(o *CloudOptions)) error {
+	return LoadGCPContext(context.Background(), Source{URL: u}, tr, options...)
+}
+
+// LoadGCPContext is LoadGCP with context and configurable Source.
+func LoadGCPContext(ctx context.Context, src Source, tr netrie.Adder, options ...func(o *CloudOptions)) error {
+	return loadCloud(ctx, src, tr, options, func(r io.Reader, add func(cr CloudRange, prefix string) error) error {
+		var doc struct {
+			CreationTime string `json:"creationTime"`
+			Prefixes     []struct {
+				IPv4Prefix string `json:"ipv4Prefix"`
+				IPv6Prefix string `json:"ipv6Prefix"`
+				Service    string `json:"service"`
+				Scope      string `json:"scope"`
+			} `json:"prefixes"`
+		}
+
+		if err := json.NewDecoder(r).Decode(&doc); err != nil {
+			return err
+		}
+
+		setBuildDate(tr, "2006-01-02T15:04:05.999999", doc.CreationTime)
+
+		for _, p := range doc.Prefixes {
+			if err := add(CloudRange{Provider: "gcp", Region: p.Scope, Service: p.Service}, p.IPv4Prefix+p.IPv6Prefix); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// LoadAzure loads Azure service tags (ServiceTags_Public_*.json) from a file or URL,
+// system service is used as a service.
+//
+// Prefixes listed in multiple tags are named by the most specific tag (with both region and service).
+func LoadAzure(u string, tr netrie.Adder, options ...func(o *CloudOptions)) error {
+	return LoadAzureContext(context.Background(), Source{URL: u}, tr, options...)
+}
+
+// LoadAzureContext is LoadAzure with context and configurable Source.
+func LoadAzureContext(ctx context.Context, src Source, tr netrie.Adder, options ...func(o *CloudOptions)) error {
+	return loadCloud(ctx, src, tr, options, func(r io.Reader, add func(cr CloudRange, prefix string) error) error {
+		var doc struct {
+			Values []struct {
+				Name       string `json:"name"`
+				Properties struct {
+					Region          string   `json:"region"`
+					SystemService   string   `json:"systemService"`
+					AddressPrefixes []string `json:"addressPrefixes"`
+				} `json:"properties"`
+			} `json:"values"`
+		}
+
+		if err := json.NewDecoder(r).Decode(&doc); err != nil {
+			return err
+		}
+
+		for _, v := range doc.Values {
+			cr := CloudRange{Provider: "azure", Region: v.Properties.Region, Service: v.Properties.SystemService}
+
+			if cr.Region != "" {
+				cr.specificity++
+			}
+
+			if cr.Service != "" {
+				cr.specificity++
+			}
+
+			for _, p := range v.Properties.AddressPrefixes {
+				if err := add(cr, p); err != nil {
+					return fmt.Errorf("%s: %w", v.Name, err)
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+// LoadOracle loads Oracle Cloud public_ip_ranges.json
+// (https://docs.oracle.com/iaas/tools/public_ip_ranges.json) from a file or URL,
+// comma-separated tags are used as a service.
+func LoadOracle(u string, tr netrie.Adder, options ...func(o *CloudOptions)) error {
+	return LoadOracleContext(context.Background(), Source{URL: u}, tr, options...)
+}
+
+// LoadOracleContext is LoadOracle with context and configurable Source.
+func LoadOracleContext(ctx context.Context, src Source, tr netrie.Adder, options ...func(o *CloudOptions)) error {
+	return loadCloud(ctx, src, tr, options, func(r io.Reader, add func(cr CloudRange, prefix string) error) error {
+		var doc struct {
+			LastUpdated string `json:"last_updated_timestamp"`
+			Regions     []struct {
+				Region string `json:"region"`
+				CIDRs  []struct {
+					CIDR string   `json:"cidr"`
+					Tags []string `json:"tags"`
+				} `json:"cidrs"`
+			} `json:"regions"`
+		}
+
+		if err := json.NewDecoder(r).Decode(&doc); err != nil {
+			return err
+		}
+
+		setBuildDate(tr, "2006-01-02T15:04:05.999999", doc.LastUpdated)
+
+		for _, reg := range doc.Regions {
+			for _, c := range reg.CIDRs {
+				cr := CloudRange{Provider: "oracle", Region: reg.Region, Service: strings.Join(c.Tags, ",")}
+
+				if err := add(cr, c.CIDR); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+// LoadCloudflare loads Cloudflare IP ranges from API response (https://api.cloudflare.com/client/v4/ips)
+// in a file or URL.
+func LoadCloudflare(u string, tr netrie.Adder, options ...func(o *CloudOptions)) error {
+	return LoadCloudflareContext(context.Background(), Source{URL: u}, tr, options...)
+}
+
+// LoadCloudflareContext is LoadCloudflare with context and configurable Source.
+func LoadCloudflareContext(ctx context.Context, src Source, tr netrie.Adder, options ...func(o *CloudOptions)) error {
+	return loadCloud(ctx, src, tr, options, func(r io.Reader, add func(cr CloudRange, prefix string) error) error {
+		var doc struct {
+			Result struct {
+				IPv4CIDRs []string `json:"ipv4_cidrs"`
+				IPv6CIDRs []string `json:"ipv6_cidrs"`
+			} `json:"result"`
+		}
+
+		if err := json.NewDecoder(r).Decode(&doc); err != nil {
+			return err
+		}
+
+		for _, p := range append(doc.Result.IPv4CIDRs, doc.Result.IPv6CIDRs...) {
+			if err := add(CloudRange{Provider: "cloudflare"}, p); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// LoadFastly loads Fastly IP ranges (https://api.fastly.com/public-ip-list) from a file or URL.
+func LoadFastly(u string, tr netrie.Adder, options ...func(o *CloudOptions)) error {
+	return LoadFastlyContext(context.Background(), Source{URL: u}, tr, options...)
+}
+
+// LoadFastlyContext is LoadFastly with context and configurable Source.
+func LoadFastlyContext(ctx context.Context, src Source, tr netrie.Adder, options ...func(o *CloudOptions)) error {
+	return loadCloud(ctx, src, tr, options, func(r io.Reader, add func(cr CloudRange, prefix string) error) error {
+		var doc struct {
+			Addresses     []string `json:"addresses"`
+			IPv6Addresses []string `json:"ipv6_addresses"`
+		}
+
+		if err := json.NewDecoder(r).Decode(&doc); err != nil {
+			return err
+		}
+
+		for _, p := range append(doc.Addresses, doc.IPv6Addresses...) {
+			if err := add(CloudRange{Provider: "fastly"}, p); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// loadCloud reads ranges with parse, resolves duplicate prefixes by specificity and adds named ranges to the trie
+// in the order of appearance.
+func loadCloud(
+	ctx context.Context,
+	src Source,
+	tr netrie.Adder,
+	options []func(o *CloudOptions),
+	parse func(r io.Reader, add func(cr CloudRange, prefix string) error) error,
+) error {
+	o := CloudOptions{}
+
+	for _, opt := range options {
+		opt(&o)
+	}
+
+	r, err := src.Open(ctx)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	var (
+		ranges   []CloudRange
+		byPrefix = map[string]int{}
+	)
+
+	err = parse(r, func(cr CloudRange, prefix string) error {
+		_, n, err := net.ParseCIDR(strings.TrimSpace(prefix))
+		if err != nil {
+			return err
+		}
+
+		cr.Prefix = n
+		k := n.String()
+
+		if i, ok := byPrefix[k]; ok {
+			if cr.specificity > ranges[i].specificity {
+				ranges[i] = cr
+			}
+
+			return nil
+		}
+
+		byPrefix[k] = len(ranges)
+		ranges = append(ranges, cr)
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	makeName := o.makeName()
+
+	for _, cr := range ranges {
+		if name := makeName(cr); name != "" {
+			if err := netrie.AddNet(tr, cr.Prefix, name); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func setBuildDate(tr netrie.Adder, layout, value string) {
+	if t, err := time.Parse(layout, value); err == nil {
+		tr.Metadata().BuildDate = t
+	}
+}
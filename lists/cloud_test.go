@@ -0,0 +1,83 @@
+package lists_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vearutop/netrie"
+	"github.com/vearutop/netrie/lists"
+)
+
+func TestLoadAWS(t *testing.T) {
+	tr := netrie.NewCIDRIndex()
+
+	require.NoError(t, lists.LoadAWS("testdata/cloud/aws-ip-ranges.json", tr))
+
+	assert.Equal(t, 6, tr.Len())
+	assert.Equal(t, "aws:af-south-1:AMAZON", tr.Lookup("3.2.34.1"))
+	assert.Equal(t, "aws:ap-northeast-2:S3", tr.Lookup("3.5.140.1"))
+	assert.Equal(t, "aws:us-east-1:EC2", tr.Lookup("18.208.0.1"))
+	assert.Equal(t, "aws:GLOBAL:CLOUDFRONT", tr.Lookup("13.32.0.1"))
+	assert.Equal(t, "aws:us-west-2:EC2", tr.Lookup("2600:1f14::1"))
+	assert.Equal(t, time.Date(2024, 1, 16, 0, 0, 0, 0, time.UTC), tr.Metadata().BuildDate)
+}
+
+func TestLoadGCP(t *testing.T) {
+	tr := netrie.NewCIDRIndex()
+
+	require.NoError(t, lists.LoadGCP("testdata/cloud/gcp-cloud.json", tr, func(o *lists.CloudOptions) {
+		o.Template = "{provider}/{region}"
+	}))
+
+	assert.Equal(t, 4, tr.Len())
+	assert.Equal(t, "gcp/africa-south1", tr.Lookup("34.1.208.1"))
+	assert.Equal(t, "gcp/africa-south1", tr.Lookup("2600:1900:8000::1"))
+	assert.Equal(t, "gcp/us-east1", tr.Lookup("35.185.128.1"))
+	assert.Equal(t, time.Date(2024, 1, 16, 0, 0, 0, 0, time.UTC), tr.Metadata().BuildDate)
+}
+
+func TestLoadAzure(t *testing.T) {
+	tr := netrie.NewCIDRIndex()
+
+	require.NoError(t, lists.LoadAzure("testdata/cloud/azure-service-tags.json", tr))
+
+	assert.Equal(t, 4, tr.Len())
+	assert.Equal(t, "azure:westus", tr.Lookup("13.64.0.1"))
+	assert.Equal(t, "azure:westus:AzureAppService", tr.Lookup("13.64.73.110"))
+	assert.Equal(t, "azure", tr.Lookup("20.36.0.1"))
+	assert.Equal(t, "azure:westus", tr.Lookup("2603:1030::1"))
+}
+
+func TestLoadOracle(t *testing.T) {
+	tr := netrie.NewCIDRIndex()
+
+	require.NoError(t, lists.LoadOracle("testdata/cloud/oracle-public-ip-ranges.json", tr, func(o *lists.CloudOptions) {
+		o.MakeName = func(r lists.CloudRange) string {
+			if r.Service != "OCI" {
+				return ""
+			}
+
+			return r.Region
+		}
+	}))
+
+	assert.Equal(t, 2, tr.Len())
+	assert.Equal(t, "us-phoenix-1", tr.Lookup("129.146.0.1"))
+	assert.Equal(t, "", tr.Lookup("134.70.8.1"))
+	assert.Equal(t, "eu-frankfurt-1", tr.Lookup("130.61.0.1"))
+}
+
+func TestLoadCloudflare(t *testing.T) {
+	tr := netrie.NewCIDRIndex()
+
+	require.NoError(t, lists.LoadCloudflare("testdata/cloud/cloudflare-ips.json", tr))
+	require.NoError(t, lists.LoadFastly("testdata/cloud/fastly-public-ip-list.json", tr))
+
+	assert.Equal(t, 8, tr.Len())
+	assert.Equal(t, "cloudflare", tr.Lookup("104.16.0.1"))
+	assert.Equal(t, "cloudflare", tr.Lookup("2606:4700::1111"))
+	assert.Equal(t, "fastly", tr.Lookup("151.101.1.1"))
+	assert.Equal(t, "fastly", tr.Lookup("2a04:4e40::1"))
+}
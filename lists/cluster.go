@@ -25,17 +25,17 @@ func parseIPOrCIDR(s string) (*net.IPNet, error) {
 	return ipNet, nil
 }
 
-// ClusterCIDRs takes IPs or CIDRs and returns minimal set of aggregated CIDRs.
+// ClusterCIDRs takes IPs, CIDRs or "start-end" IP ranges and returns minimal set of aggregated CIDRs.
 func ClusterCIDRs(input []string) ([]*net.IPNet, error) {
 	nets := make([]*net.IPNet, 0, len(input))
 
 	for _, s := range input {
-		ipNet, err := parseIPOrCIDR(s)
+		n, err := parseNets(s)
 		if err != nil {
 			return nil, fmt.Errorf("parse error for %q: %w", s, err)
 		}
 
-		nets = append(nets, ipNet)
+		nets = append(nets, n...)
 	}
 
 	merged := cidrmerge.Merge(nets)
@@ -58,6 +58,11 @@ func TestClusterCIDRs(t *testing.T) {
 			input:    []string{"10.0.0.0/25", "10.0.0.128/26"},
 			expected: []string{"10.0.0.0/25", "10.0.0.128/26"},
 		},
+		{
+			name:     "IPv4 range",
+			input:    []string{"10.0.0.1-10.0.0.6", "10.0.0.7"},
+			expected: []string{"10.0.0.1/32", "10.0.0.2/31", "10.0.0.4/30"},
+		},
 		{
 			name:     "Single IP",
 			input:    []string{"8.8.8.8"},
@@ -0,0 +1,208 @@
+package lists
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/vearutop/netrie"
+)
+
+// CSVOptions configures LoadFromCSV.
+//
+// Columns are referenced by header name (if Header is enabled) or by zero-based index.
+type CSVOptions struct {
+	Comma   rune // Field delimiter, default ','.
+	Comment rune // Lines starting with this character are skipped, default '#'.
+	Header  bool // First row is a header.
+
+	CIDRColumn  string // Column with IP, CIDR or "start-end" range of IPs.
+	StartColumn string // Column with range start IP, used when CIDRColumn is empty.
+	EndColumn   string // Column with range end IP (inclusive), used when CIDRColumn is empty.
+
+	NameColumns   []string // Columns to build the name from.
+	NameSeparator string   // Separator of name parts, default ":".
+	Name          string   // Constant name, used when NameColumns is empty.
+
+	// MakeName builds a name from a row, overrides NameColumns and Name.
+	// Empty name skips the row.
+	MakeName func(row []string) string
+}
+
+// LoadFromCSV loads CIDRs or start-end IP ranges from a CSV file or URL and adds them to the provided Adder.
+// Ranges are decomposed into a minimal set of CIDRs, IPs may be in text form or decimal integers.
+func LoadFromCSV(u string, tr netrie.Adder, options ...func(o *CSVOptions)) error {
+	return LoadFromCSVContext(context.Background(), Source{URL: u}, tr, options...)
+}
+
+// LoadFromCSVContext is LoadFromCSV with context and configurable Source.
+func LoadFromCSVContext(ctx context.Context, src Source, tr netrie.Adder, options ...func(o *CSVOptions)) error {
+	o := CSVOptions{
+		Comma:         ',',
+		Comment:       '#',
+		NameSeparator: ":",
+	}
+
+	for _, opt := range options {
+		opt(&o)
+	}
+
+	r, err := src.Open(ctx)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	return loadCSV(r, tr, o)
+}
+
+func loadCSV(r io.Reader, tr netrie.Adder, o CSVOptions) error {
+	cr := csv.NewReader(r)
+	cr.Comma = o.Comma
+	cr.Comment = o.Comment
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	cr.ReuseRecord = true
+
+	var header []string
+
+	if o.Header {
+		row, err := cr.Read()
+		if err != nil {
+			return fmt.Errorf("read header: %w", err)
+		}
+
+		header = append(header, row...)
+	}
+
+	col := func(c string) (int, error) {
+		for i, h := range header {
+			if strings.TrimSpace(h) == c {
+				return i, nil
+			}
+		}
+
+		i, err := strconv.Atoi(c)
+		if err != nil || i < 0 {
+			return 0, fmt.Errorf("unknown column: %q", c)
+		}
+
+		return i, nil
+	}
+
+	cidrCol, startCol, endCol := -1, -1, -1
+
+	var err error
+
+	switch {
+	case o.CIDRColumn != "":
+		if cidrCol, err = col(o.CIDRColumn); err != nil {
+			return err
+		}
+	case o.StartColumn != "" && o.EndColumn != "":
+		if startCol, err = col(o.StartColumn); err != nil {
+			return err
+		}
+
+		if endCol, err = col(o.EndColumn); err != nil {
+			return err
+		}
+	default:
+		return errors.New("CIDR column or start and end columns are required")
+	}
+
+	nameCols := make([]int, 0, len(o.NameColumns))
+
+	for _, c := range o.NameColumns {
+		i, err := col(c)
+		if err != nil {
+			return err
+		}
+
+		nameCols = append(nameCols, i)
+	}
+
+	makeName := o.MakeName
+	if makeName == nil {
+		makeName = func(row []string) string {
+			if len(nameCols) == 0 {
+				return o.Name
+			}
+
+			parts := make([]string, len(nameCols))
+			for i, c := range nameCols {
+				if c < len(row) {
+					parts[i] = strings.TrimSpace(row[c])
+				}
+			}
+
+			return strings.Join(parts, o.NameSeparator)
+		}
+	}
+
+	for {
+		row, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("read CSV: %w", err)
+		}
+
+		line, _ := cr.FieldPos(0)
+
+		name := makeName(row)
+		if name == "" {
+			continue
+		}
+
+		if cidrCol != -1 {
+			if cidrCol >= len(row) {
+				return fmt.Errorf("line %d: missing CIDR column", line)
+			}
+
+			nets, err := parseNets(strings.TrimSpace(row[cidrCol]))
+			if err != nil {
+				return fmt.Errorf("line %d: %w", line, err)
+			}
+
+			for _, n := range nets {
+				if err := netrie.AddNet(tr, n, name); err != nil {
+					return fmt.Errorf("line %d: %w", line, err)
+				}
+			}
+
+			continue
+		}
+
+		if startCol >= len(row) || endCol >= len(row) {
+			return fmt.Errorf("line %d: missing range columns", line)
+		}
+
+		start, err := parseAddr(row[startCol])
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+
+		end, err := parseAddr(row[endCol])
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+
+		nets, err := netrie.RangeNets(start, end)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+
+		for _, n := range nets {
+			if err := netrie.AddNet(tr, n, name); err != nil {
+				return fmt.Errorf("line %d: %w", line, err)
+			}
+		}
+	}
+}
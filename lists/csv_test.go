@@ -0,0 +1,121 @@
+package lists_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vearutop/netrie"
+	"github.com/vearutop/netrie/lists"
+)
+
+func TestLoadFromCSV_dbip(t *testing.T) {
+	tr := netrie.NewCIDRIndex()
+
+	require.NoError(t, lists.LoadFromCSV("testdata/dbip-country-lite.csv", tr, func(o *lists.CSVOptions) {
+		o.StartColumn = "0"
+		o.EndColumn = "1"
+		o.NameColumns = []string{"2"}
+	}))
+
+	// 1.0.1.0-1.0.3.255 is decomposed into 1.0.1.0/24 and 1.0.2.0/23.
+	assert.Equal(t, 5, tr.Len())
+	assert.Equal(t, 3, tr.LenNames())
+	assert.Equal(t, "AU", tr.Lookup("1.0.0.1"))
+	assert.Equal(t, "CN", tr.Lookup("1.0.1.1"))
+	assert.Equal(t, "CN", tr.Lookup("1.0.3.255"))
+	assert.Equal(t, "AU", tr.Lookup("1.0.7.1"))
+	assert.Equal(t, "", tr.Lookup("1.0.8.1"))
+	assert.Equal(t, "JP", tr.Lookup("2001:200::1"))
+}
+
+func TestLoadFromCSV_ip2location(t *testing.T) {
+	tr := netrie.NewCIDRIndex()
+
+	require.NoError(t, lists.LoadFromCSV("testdata/ip2location-lite.csv", tr, func(o *lists.CSVOptions) {
+		o.StartColumn = "0"
+		o.EndColumn = "1"
+		o.MakeName = func(row []string) string {
+			if row[2] == "-" {
+				return ""
+			}
+
+			return row[2] + ":" + row[5]
+		}
+	}))
+
+	assert.Equal(t, "US:Los Angeles", tr.Lookup("1.0.0.1"))
+	assert.Equal(t, "CN:Fuzhou", tr.Lookup("1.0.1.1"))
+	assert.Equal(t, "CN:Fuzhou", tr.Lookup("1.0.3.255"))
+	assert.Equal(t, "", tr.Lookup("0.1.2.3"))
+}
+
+func TestLoadFromCSV_header(t *testing.T) {
+	tr := netrie.NewCIDRIndex()
+
+	require.NoError(t, lists.LoadFromCSV("testdata/inventory.csv", tr, func(o *lists.CSVOptions) {
+		o.Comma = ';'
+		o.Header = true
+		o.CIDRColumn = "subnet"
+		o.NameColumns = []string{"owner", "site", "rack"}
+		o.NameSeparator = "/"
+	}))
+
+	assert.Equal(t, 8, tr.Len())
+	assert.Equal(t, "team-a/ams1/r01", tr.Lookup("10.0.0.1"))
+	assert.Equal(t, "team-b/ams1/r02", tr.Lookup("10.0.1.1"))
+	assert.Equal(t, "team-c/fra2/", tr.Lookup("10.1.200.1"))
+	assert.Equal(t, "team-a/fra2/r07", tr.Lookup("2001:db8:1::1"))
+	assert.Equal(t, "team-d/lab/", tr.Lookup("192.168.0.20"))
+	assert.Equal(t, "", tr.Lookup("192.168.0.21"))
+}
+
+func TestLoadFromCSV_errors(t *testing.T) {
+	tr := netrie.NewCIDRIndex()
+
+	require.EqualError(t, lists.LoadFromCSV("testdata/inventory.csv", tr, func(o *lists.CSVOptions) {
+		o.Header = true
+		o.Comma = ';'
+		o.CIDRColumn = "network"
+	}), `unknown column: "network"`)
+
+	require.EqualError(t, lists.LoadFromCSV("testdata/inventory.csv", tr, func(o *lists.CSVOptions) {
+		o.Comma = ';'
+		o.Name = "foo"
+	}), "CIDR column or start and end columns are required")
+
+	require.EqualError(t, lists.LoadFromCSV("testdata/inventory.csv", tr, func(o *lists.CSVOptions) {
+		o.Comma = ';'
+		o.CIDRColumn = "0"
+		o.Name = "foo"
+	}), "line 2: invalid CIDR address: subnet")
+}
+
+func TestLoadFromCSV_tooManyNames(t *testing.T) {
+	var csv strings.Builder
+	for i := range 40000 {
+		_, _ = fmt.Fprintf(&csv, "10.%d.%d.0/24,name-%d\n", i>>8, i&0xff, i)
+	}
+
+	fn := filepath.Join(t.TempDir(), "names.csv")
+	require.NoError(t, os.WriteFile(fn, []byte(csv.String()), 0o600))
+
+	cidrName := func(o *lists.CSVOptions) {
+		o.CIDRColumn = "0"
+		o.NameColumns = []string{"1"}
+	}
+
+	tr := netrie.NewCIDRIndex()
+	err := lists.LoadFromCSV(fn, tr, cidrName)
+	require.ErrorIs(t, err, netrie.ErrTooManyNames)
+	assert.Contains(t, err.Error(), "line 32768")
+
+	large := netrie.NewCIDRLargeIndex()
+	require.NoError(t, lists.LoadFromCSV(fn, large, cidrName))
+	assert.Equal(t, 40000, large.LenNames())
+	assert.Equal(t, "name-39999", large.Lookup("10.156.63.1"))
+}
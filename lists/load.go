@@ -2,12 +2,11 @@ package lists
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net"
-	"net/http"
-	"os"
 	"strconv"
 	"strings"
 
@@ -18,8 +17,13 @@ import (
 // The CIDRs are identified by the specified name.
 // Returns an error if the resource is unreachable, JSON is invalid, or if there are issues with adding CIDRs.
 func LoadFromJSONBruteForce[S int16 | int32](u string, tr *netrie.CIDRIndex[S], name string) error {
-	return LoadFromJSON(
-		u,
+	return LoadFromJSONBruteForceContext(context.Background(), Source{URL: u}, tr, name)
+}
+
+// LoadFromJSONBruteForceContext is LoadFromJSONBruteForce with context and configurable Source.
+func LoadFromJSONBruteForceContext[S int16 | int32](ctx context.Context, src Source, tr *netrie.CIDRIndex[S], name string) error {
+	return LoadFromJSONContext(
+		ctx, src,
 		func(path []string, value interface{}) error {
 			if s, ok := value.(string); ok {
 				if _, _, err := net.ParseCIDR(s); err != nil {
@@ -35,14 +39,16 @@ func LoadFromJSONBruteForce[S int16 | int32](u string, tr *netrie.CIDRIndex[S],
 // LoadFromJSON fetches a JSON resource from a URL and processes its scalar values using the provided callback function.
 // Returns an error if the HTTP request, JSON decoding, or callback function execution fails.
 func LoadFromJSON(u string, cb func(path []string, value interface{}) error) error {
-	r, err := makeReader(u)
-	if r != nil {
-		defer r.Close()
-	}
+	return LoadFromJSONContext(context.Background(), Source{URL: u}, cb)
+}
 
+// LoadFromJSONContext is LoadFromJSON with context and configurable Source.
+func LoadFromJSONContext(ctx context.Context, src Source, cb func(path []string, value interface{}) error) error {
+	r, err := src.Open(ctx)
 	if err != nil {
 		return err
 	}
+	defer r.Close()
 
 	if err := walkJSON(r, cb); err != nil {
 		return err
@@ -51,45 +57,20 @@ func LoadFromJSON(u string, cb func(path []string, value interface{}) error) err
 	return nil
 }
 
-func makeReader(u string) (io.ReadCloser, error) {
-	if !strings.HasPrefix(u, "http://") && !strings.HasPrefix(u, "https://") {
-		f, err := os.Open(u)
-		if err != nil {
-			return nil, err
-		}
-
-		return f, nil
-	}
-
-	req, err := http.NewRequest(http.MethodGet, u, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	resp, err := http.DefaultTransport.RoundTrip(req)
-	if err != nil {
-		return nil, err
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return resp.Body, fmt.Errorf("bad HTTP status code: %d", resp.StatusCode)
-	}
-
-	return resp.Body, nil
-}
-
 // LoadFromTextCB loads data from a file or URL, processes each non-empty, non-comment line using the provided callback function.
 // The callback receives a sanitized string extracted from each line, and errors from the callback halt further processing.
 // Returns an error if any file operation, HTTP request, or callback execution fails.
 func LoadFromTextCB(u string, cb func(value string) error) error {
-	r, err := makeReader(u)
-	if r != nil {
-		defer r.Close()
-	}
+	return LoadFromTextCBContext(context.Background(), Source{URL: u}, cb)
+}
 
+// LoadFromTextCBContext is LoadFromTextCB with context and configurable Source.
+func LoadFromTextCBContext(ctx context.Context, src Source, cb func(value string) error) error {
+	r, err := src.Open(ctx)
 	if err != nil {
 		return err
 	}
+	defer r.Close()
 
 	s := bufio.NewScanner(r)
 
@@ -101,21 +82,34 @@ func LoadFromTextCB(u string, cb func(value string) error) error {
 
 		line = strings.SplitN(line, ",", 2)[0]
 
+		// Keep "start - end" range as a single value.
+		line = strings.Replace(line, " - ", "-", 1)
+
 		line = strings.SplitN(line, " ", 2)[0]
 
 		if err := cb(line); err != nil {
+			// Last line of a failed read is truncated, read error is the cause.
+			if serr := s.Err(); serr != nil {
+				return serr
+			}
+
 			return err
 		}
 	}
 
-	return nil
+	return s.Err()
 }
 
-// LoadFromTextGroupCIDRs loads CIDR ranges or IPs from a source, aggregates them, and adds to a netrie.Adder with a given name.
+// LoadFromTextGroupCIDRs loads CIDRs, IPs or "start-end" IP ranges from a source, aggregates them, and adds to a netrie.Adder with a given name.
 func LoadFromTextGroupCIDRs(u string, tr netrie.Adder, name string) error {
+	return LoadFromTextGroupCIDRsContext(context.Background(), Source{URL: u}, tr, name)
+}
+
+// LoadFromTextGroupCIDRsContext is LoadFromTextGroupCIDRs with context and configurable Source.
+func LoadFromTextGroupCIDRsContext(ctx context.Context, src Source, tr netrie.Adder, name string) error {
 	var cidrs []string
 
-	err := LoadFromTextCB(u, func(value string) error {
+	err := LoadFromTextCBContext(ctx, src, func(value string) error {
 		cidrs = append(cidrs, value)
 		return nil
 	})
@@ -129,18 +123,37 @@ func LoadFromTextGroupCIDRs(u string, tr netrie.Adder, name string) error {
 	}
 
 	for _, n := range nets {
-		tr.AddNet(n, name)
+		if err := netrie.AddNet(tr, n, name); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-// LoadFromText loads CIDR ranges from a file or URL and adds them to the provided Adder with the specified name.
+// LoadFromText loads IPs, CIDRs or "start-end" IP ranges from a file or URL and adds them to the provided Adder
+// with the specified name.
 // It skips empty lines and comments and halts processing on the first error encountered.
 // Returns an error if reading data, processing lines, or adding CIDRs fails.
 func LoadFromText(u string, tr netrie.Adder, name string) error {
-	return LoadFromTextCB(u, func(s string) error {
-		return tr.AddCIDR(s, name)
+	return LoadFromTextContext(context.Background(), Source{URL: u}, tr, name)
+}
+
+// LoadFromTextContext is LoadFromText with context and configurable Source.
+func LoadFromTextContext(ctx context.Context, src Source, tr netrie.Adder, name string) error {
+	return LoadFromTextCBContext(ctx, src, func(s string) error {
+		nets, err := parseNets(s)
+		if err != nil {
+			return fmt.Errorf("invalid CIDR (%s): %v", name, s)
+		}
+
+		for _, n := range nets {
+			if err := netrie.AddNet(tr, n, name); err != nil {
+				return err
+			}
+		}
+
+		return nil
 	})
 }
 
@@ -0,0 +1,60 @@
+package lists_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vearutop/netrie"
+	"github.com/vearutop/netrie/lists"
+)
+
+func TestLoadFromText(t *testing.T) {
+	tr := netrie.NewCIDRIndex()
+	require.NoError(t, lists.LoadFromText("testdata/ranges.txt", tr, "net1"))
+
+	assert.Equal(t, 7, tr.Len())
+	assert.Equal(t, "", tr.Lookup("10.0.0.0"))
+	assert.Equal(t, "net1", tr.Lookup("10.0.0.1"))
+	assert.Equal(t, "net1", tr.Lookup("10.0.0.6"))
+	assert.Equal(t, "", tr.Lookup("10.0.0.7"))
+	assert.Equal(t, "", tr.Lookup("10.0.1.100"))
+	assert.Equal(t, "net1", tr.Lookup("10.0.2.100"))
+	assert.Equal(t, "net1", tr.Lookup("10.0.3.100"))
+	assert.Equal(t, "net1", tr.Lookup("10.0.4.1"))
+	assert.Equal(t, "", tr.Lookup("10.0.4.2"))
+}
+
+func TestLoadFromTextGroupCIDRs(t *testing.T) {
+	tr := netrie.NewCIDRIndex()
+	require.NoError(t, lists.LoadFromTextGroupCIDRs("testdata/ranges.txt", tr, "net1"))
+
+	// 10.0.2.0/24 and 10.0.3.0/24 are merged.
+	assert.Equal(t, 6, tr.Len())
+	assert.Equal(t, "net1", tr.Lookup("10.0.0.1"))
+	assert.Equal(t, "", tr.Lookup("10.0.0.7"))
+	assert.Equal(t, "net1", tr.Lookup("10.0.3.100"))
+	assert.Equal(t, "net1", tr.Lookup("10.0.4.1"))
+}
+
+func TestLoadFromText_tor(t *testing.T) {
+	tr := netrie.NewCIDRIndex()
+	require.NoError(t, lists.LoadFromText("testdata/torlist.txt", tr, "tor"))
+
+	assert.Equal(t, "tor", tr.Lookup("102.130.113.9"))
+	assert.Equal(t, "", tr.Lookup("102.130.113.10"))
+}
+
+func TestLoadFromText_adderErr(t *testing.T) {
+	b := netrie.NewBuilder(func(o *netrie.BuilderOptions) {
+		o.Conflict = netrie.FailOnConflict
+	})
+
+	require.NoError(t, lists.LoadFromText("testdata/ranges.txt", b, "net1"))
+	require.ErrorIs(t, lists.LoadFromText("testdata/ranges.txt", b, "net2"), netrie.ErrConflict)
+	require.ErrorIs(t, lists.LoadFromTextGroupCIDRs("testdata/ranges.txt", b, "net2"), netrie.ErrConflict)
+
+	sb := netrie.NewSortedBuilder()
+	require.NoError(t, sb.AddCIDR("192.168.0.0/16", "last"))
+	require.ErrorIs(t, lists.LoadFromText("testdata/ranges.txt", sb, "net1"), netrie.ErrUnsorted)
+}
@@ -0,0 +1,64 @@
+package lists
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+	"net/netip"
+	"strings"
+
+	"github.com/vearutop/netrie"
+)
+
+// parseNets parses an IP, a CIDR or an inclusive "start-end" range of IPs into a list of CIDRs.
+func parseNets(s string) ([]*net.IPNet, error) {
+	if i := strings.IndexByte(s, '-'); i != -1 {
+		start, err := parseAddr(s[:i])
+		if err != nil {
+			return nil, err
+		}
+
+		end, err := parseAddr(s[i+1:])
+		if err != nil {
+			return nil, err
+		}
+
+		return netrie.RangeNets(start, end)
+	}
+
+	n, err := parseIPOrCIDR(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return []*net.IPNet{n}, nil
+}
+
+// parseAddr parses an IP address in text form or as a decimal integer (used by IP2Location CSVs).
+// Integers that fit into 32 bits are treated as IPv4.
+func parseAddr(s string) (netip.Addr, error) {
+	s = strings.TrimSpace(s)
+
+	if addr, err := netip.ParseAddr(s); err == nil {
+		return addr.Unmap(), nil
+	}
+
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok || n.Sign() < 0 || n.BitLen() > 128 {
+		return netip.Addr{}, fmt.Errorf("invalid IP: %q", s)
+	}
+
+	if n.BitLen() <= 32 {
+		var b [4]byte
+
+		n.FillBytes(b[:])
+
+		return netip.AddrFrom4(b), nil
+	}
+
+	var b [16]byte
+
+	n.FillBytes(b[:])
+
+	return netip.AddrFrom16(b).Unmap(), nil
+}
@@ -0,0 +1,253 @@
+// Package rir provides importer of RIR delegated statistics (delegated-*-extended-latest files).
+package rir
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/thcyron/cidrmerge"
+	"github.com/vearutop/netrie"
+)
+
+// Record is an IP record of RIR delegated statistics.
+type Record struct {
+	Registry string
+	CC       string // ISO 3166 country code, can be empty or "ZZ" for unallocated resources.
+	Type     string // "ipv4" or "ipv6".
+	Start    string // First address.
+	Value    string // Number of addresses for IPv4, prefix length for IPv6.
+	Date     string // Allocation date as YYYYMMDD.
+	Status   string // "allocated", "assigned", "available" or "reserved".
+	OpaqueID string // Holder id, only available in extended files.
+}
+
+// Options defines configuration of Load.
+type Options struct {
+	// MakeName makes a name for a record, records with empty names are skipped.
+	MakeName func(r Record) string
+
+	// Statuses to load, default "allocated" and "assigned".
+	Statuses []string
+}
+
+// CountryCode configures Options to name networks by country code.
+func CountryCode(o *Options) {
+	o.MakeName = func(r Record) string {
+		if r.CC == "ZZ" {
+			return ""
+		}
+
+		return r.CC
+	}
+}
+
+// Status configures Options to name networks by status and to load all statuses.
+func Status(o *Options) {
+	o.MakeName = func(r Record) string {
+		return r.Status
+	}
+	o.Statuses = []string{"allocated", "assigned", "available", "reserved"}
+}
+
+// OpaqueID configures Options to name networks by registry and opaque holder id, e.g. "apnic:A91872ED".
+func OpaqueID(o *Options) {
+	o.MakeName = func(r Record) string {
+		if r.OpaqueID == "" {
+			return ""
+		}
+
+		return r.Registry + ":" + r.OpaqueID
+	}
+}
+
+// Load reads RIR delegated statistics files and adds IPv4 and IPv6 records to the trie.
+// Networks with the same name are aggregated with cidrmerge, by default networks are named with country code.
+// Metadata build date is set to the latest serial date of the files.
+func Load(tr netrie.Adder, paths []string, options ...func(o *Options)) error {
+	o := &Options{}
+	CountryCode(o)
+
+	o.Statuses = []string{"allocated", "assigned"}
+
+	for _, opt := range options {
+		opt(o)
+	}
+
+	statuses := make(map[string]bool, len(o.Statuses))
+	for _, s := range o.Statuses {
+		statuses[s] = true
+	}
+
+	byName := make(map[string][]*net.IPNet)
+
+	var buildDate time.Time
+
+	for _, fn := range paths {
+		d, err := loadFile(fn, func(r Record) error {
+			if !statuses[r.Status] {
+				return nil
+			}
+
+			name := o.MakeName(r)
+			if name == "" {
+				return nil
+			}
+
+			nets, err := recordNets(r)
+			if err != nil {
+				return err
+			}
+
+			byName[name] = append(byName[name], nets...)
+
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w", fn, err)
+		}
+
+		if d.After(buildDate) {
+			buildDate = d
+		}
+	}
+
+	meta := tr.Metadata()
+	if !buildDate.IsZero() {
+		meta.BuildDate = buildDate
+	}
+
+	if meta.Name == "" {
+		meta.Name = "RIR delegated statistics"
+	}
+
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, n := range cidrmerge.Merge(byName[name]) {
+			if err := netrie.AddNet(tr, n, name); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// loadFile calls cb for every IP record of the file and returns the date of the serial in the version line.
+func loadFile(fn string, cb func(r Record) error) (time.Time, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer f.Close()
+
+	var buildDate time.Time
+
+	s := bufio.NewScanner(f)
+	line := 0
+
+	for s.Scan() {
+		line++
+
+		l := strings.TrimSpace(s.Text())
+		if l == "" || l[0] == '#' {
+			continue
+		}
+
+		fields := strings.Split(l, "|")
+
+		// Version line: version|registry|serial|records|startdate|enddate|UTCoffset.
+		if len(fields) >= 3 && fields[0] != "" && fields[0][0] >= '0' && fields[0][0] <= '9' {
+			if d, err := time.Parse("20060102", fields[2]); err == nil {
+				buildDate = d
+			}
+
+			continue
+		}
+
+		// Summary line: registry|*|type|*|count|summary.
+		if len(fields) == 6 && fields[5] == "summary" {
+			continue
+		}
+
+		if len(fields) < 7 {
+			return time.Time{}, fmt.Errorf("line %d: unexpected number of fields: %d", line, len(fields))
+		}
+
+		if fields[2] != "ipv4" && fields[2] != "ipv6" {
+			continue
+		}
+
+		r := Record{
+			Registry: fields[0],
+			CC:       fields[1],
+			Type:     fields[2],
+			Start:    fields[3],
+			Value:    fields[4],
+			Date:     fields[5],
+			Status:   fields[6],
+		}
+
+		if len(fields) > 7 {
+			r.OpaqueID = fields[7]
+		}
+
+		if err := cb(r); err != nil {
+			return time.Time{}, fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+
+	if err := s.Err(); err != nil {
+		return time.Time{}, err
+	}
+
+	return buildDate, nil
+}
+
+// recordNets converts IPv4 start|count or IPv6 prefix|len record into CIDRs.
+func recordNets(r Record) ([]*net.IPNet, error) {
+	start, err := netip.ParseAddr(r.Start)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := strconv.ParseUint(r.Value, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value %q: %w", r.Value, err)
+	}
+
+	if r.Type == "ipv6" {
+		p, err := start.Prefix(int(v))
+		if err != nil {
+			return nil, err
+		}
+
+		return []*net.IPNet{{IP: p.Addr().AsSlice(), Mask: net.CIDRMask(p.Bits(), 128)}}, nil
+	}
+
+	if !start.Is4() || v == 0 || v > 1<<32 {
+		return nil, fmt.Errorf("invalid IPv4 record %s|%s", r.Start, r.Value)
+	}
+
+	b := start.As4()
+	end := uint64(b[0])<<24 | uint64(b[1])<<16 | uint64(b[2])<<8 | uint64(b[3])
+	end += v - 1
+
+	if end > 0xffffffff {
+		return nil, fmt.Errorf("invalid IPv4 record %s|%s: range overflow", r.Start, r.Value)
+	}
+
+	return netrie.RangeNets(start, netip.AddrFrom4([4]byte{byte(end >> 24), byte(end >> 16), byte(end >> 8), byte(end)}))
+}
@@ -0,0 +1,68 @@
+package rir_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vearutop/netrie"
+	"github.com/vearutop/netrie/lists/rir"
+)
+
+var files = []string{
+	"testdata/delegated-apnic-extended-latest",
+	"testdata/delegated-ripencc-extended-latest",
+}
+
+func TestLoad(t *testing.T) {
+	tr := netrie.NewCIDRIndex()
+
+	require.NoError(t, rir.Load(tr, files))
+
+	assert.Equal(t, 5, tr.LenNames())
+	assert.Equal(t, "AU", tr.Lookup("1.0.0.1"))
+	assert.Equal(t, "CN", tr.Lookup("1.0.1.1"))
+	assert.Equal(t, "CN", tr.Lookup("1.0.3.255"))
+	assert.Equal(t, "AU", tr.Lookup("1.0.6.255"))
+	assert.Equal(t, "", tr.Lookup("1.0.7.1"))
+	assert.Equal(t, "", tr.Lookup("1.0.8.1"))
+	assert.Equal(t, "GB", tr.Lookup("2.16.3.255"))
+	assert.Equal(t, "NL", tr.Lookup("2.16.7.231"))
+	assert.Equal(t, "", tr.Lookup("2.16.7.232"))
+	assert.Equal(t, "JP", tr.Lookup("2001:200:1fff::1"))
+	assert.Equal(t, "", tr.Lookup("2001:200:2000::1"))
+	assert.Equal(t, "CN", tr.Lookup("2001:251::1"))
+	assert.Equal(t, "", tr.Lookup("2a00::1"))
+
+	assert.Equal(t, time.Date(2024, 1, 16, 0, 0, 0, 0, time.UTC), tr.Metadata().BuildDate)
+	assert.Equal(t, "RIR delegated statistics", tr.Metadata().Name)
+}
+
+func TestLoad_status(t *testing.T) {
+	tr := netrie.NewCIDRIndex()
+
+	require.NoError(t, rir.Load(tr, files, rir.Status))
+
+	assert.Equal(t, "assigned", tr.Lookup("1.0.0.1"))
+	assert.Equal(t, "allocated", tr.Lookup("1.0.1.1"))
+	assert.Equal(t, "available", tr.Lookup("1.0.8.1"))
+	assert.Equal(t, "reserved", tr.Lookup("2a00::1"))
+}
+
+func TestLoad_opaqueID(t *testing.T) {
+	tr := netrie.NewCIDRIndex()
+
+	require.NoError(t, rir.Load(tr, files, rir.OpaqueID))
+
+	assert.Equal(t, "apnic:A91872ED", tr.Lookup("1.0.0.1"))
+	assert.Equal(t, "apnic:A92E1062", tr.Lookup("1.0.2.1"))
+	assert.Equal(t, "apnic:A92E1062", tr.Lookup("2001:250::1"))
+	assert.Equal(t, "ripencc:a3f2b1c4-1111-2222-3333-444455556666", tr.Lookup("2.16.0.1"))
+}
+
+func TestLoad_error(t *testing.T) {
+	tr := netrie.NewCIDRIndex()
+
+	require.Error(t, rir.Load(tr, []string{"testdata/missing"}))
+}
@@ -0,0 +1,337 @@
+package lists
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/vearutop/netrie"
+)
+
+// JSONSelectorOptions configures LoadFromJSONSelector.
+//
+// Selectors are JSONPath-like expressions of dot-separated object keys and array indexes,
+// e.g. "prefixes[*].ip_prefix" or "$.values[*].properties.addressPrefixes[*]".
+// Supported segments are "key", "*" (any key), "[N]" (array element), "[*]" (any array element)
+// and "['key']" (key with special characters).
+type JSONSelectorOptions struct {
+	CIDR string // Selector of IPs, CIDRs or "start-end" ranges of IPs, an array at the end is expanded.
+
+	// NameSelectors select name parts, values are correlated with CIDR by elements of common arrays,
+	// e.g. "prefixes[*].region" is taken from the same element of "prefixes" as "prefixes[*].ip_prefix".
+	NameSelectors []string
+	NameSeparator string // Separator of name parts, default ":".
+	Name          string // Constant name, used when NameSelectors is empty.
+
+	// MakeName builds a name from values of NameSelectors, overrides NameSeparator and Name.
+	// Empty name skips the value.
+	MakeName func(parts []string) string
+}
+
+// LoadFromJSONSelector loads IPs, CIDRs or "start-end" IP ranges selected from a JSON file or URL
+// and adds them to the provided Adder with names selected from the same document.
+// Document is loaded in memory.
+func LoadFromJSONSelector(u string, tr netrie.Adder, options ...func(o *JSONSelectorOptions)) error {
+	return LoadFromJSONSelectorContext(context.Background(), Source{URL: u}, tr, options...)
+}
+
+// LoadFromJSONSelectorContext is LoadFromJSONSelector with context and configurable Source.
+func LoadFromJSONSelectorContext(ctx context.Context, src Source, tr netrie.Adder, options ...func(o *JSONSelectorOptions)) error {
+	o := JSONSelectorOptions{
+		NameSeparator: ":",
+	}
+
+	for _, opt := range options {
+		opt(&o)
+	}
+
+	if o.CIDR == "" {
+		return errors.New("CIDR selector is required")
+	}
+
+	cidrSel, err := parseSelector(o.CIDR)
+	if err != nil {
+		return err
+	}
+
+	nameSels := make([]selector, 0, len(o.NameSelectors))
+
+	for _, s := range o.NameSelectors {
+		ns, err := parseSelector(s)
+		if err != nil {
+			return err
+		}
+
+		nameSels = append(nameSels, ns)
+	}
+
+	makeName := o.MakeName
+	if makeName == nil {
+		makeName = func(parts []string) string {
+			if len(nameSels) == 0 {
+				return o.Name
+			}
+
+			return strings.Join(parts, o.NameSeparator)
+		}
+	}
+
+	r, err := src.Open(ctx)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	var doc any
+
+	if err := dec.Decode(&doc); err != nil {
+		return fmt.Errorf("decode JSON: %w", err)
+	}
+
+	parts := make([]string, len(nameSels))
+
+	return cidrSel.match(doc, nil, func(path []pathElem, v any) error {
+		for i, ns := range nameSels {
+			parts[i] = ns.correlated(doc, cidrSel, path)
+		}
+
+		name := makeName(parts)
+		if name == "" {
+			return nil
+		}
+
+		values := []any{v}
+		if a, ok := v.([]any); ok {
+			values = a
+		}
+
+		for _, v := range values {
+			s := scalarString(v)
+			if s == "" {
+				continue
+			}
+
+			nets, err := parseNets(strings.TrimSpace(s))
+			if err != nil {
+				return fmt.Errorf("%s: %w", formatPath(path), err)
+			}
+
+			for _, n := range nets {
+				if err := netrie.AddNet(tr, n, name); err != nil {
+					return fmt.Errorf("%s: %w", formatPath(path), err)
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+// selectorSegment is an object key or an array index, or a wildcard of either.
+type selectorSegment struct {
+	key   string
+	index int // -1 for any element of array.
+	array bool
+	any   bool // Any key of object.
+}
+
+type selector []selectorSegment
+
+// pathElem is a concrete object key or array index matched by a segment.
+type pathElem struct {
+	key   string
+	index int
+}
+
+func parseSelector(s string) (selector, error) {
+	src := s
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "$"), ".")
+
+	var sel selector
+
+	for s != "" {
+		switch {
+		case strings.HasPrefix(s, "['") || strings.HasPrefix(s, `["`):
+			q := s[1]
+
+			end := strings.IndexByte(s[2:], q)
+			if end == -1 || !strings.HasPrefix(s[2+end+1:], "]") {
+				return nil, fmt.Errorf("invalid selector %q: unterminated key", src)
+			}
+
+			sel = append(sel, selectorSegment{key: s[2 : 2+end]})
+			s = s[2+end+2:]
+		case s[0] == '[':
+			end := strings.IndexByte(s, ']')
+			if end == -1 {
+				return nil, fmt.Errorf("invalid selector %q: unterminated index", src)
+			}
+
+			seg := selectorSegment{array: true, index: -1}
+
+			if idx := s[1:end]; idx != "*" {
+				i, err := strconv.Atoi(idx)
+				if err != nil || i < 0 {
+					return nil, fmt.Errorf("invalid selector %q: bad index %q", src, idx)
+				}
+
+				seg.index = i
+			}
+
+			sel = append(sel, seg)
+			s = s[end+1:]
+		default:
+			end := strings.IndexAny(s, ".[")
+			if end == -1 {
+				end = len(s)
+			}
+
+			key := s[:end]
+			if key == "" {
+				return nil, fmt.Errorf("invalid selector %q: empty key", src)
+			}
+
+			sel = append(sel, selectorSegment{key: key, any: key == "*"})
+			s = s[end:]
+		}
+
+		s = strings.TrimPrefix(s, ".")
+	}
+
+	if len(sel) == 0 {
+		return nil, fmt.Errorf("invalid selector %q: empty", src)
+	}
+
+	return sel, nil
+}
+
+// match calls cb for every value matching selector, with the concrete path of the value.
+func (sel selector) match(v any, path []pathElem, cb func(path []pathElem, v any) error) error {
+	if len(sel) == 0 {
+		return cb(path, v)
+	}
+
+	seg := sel[0]
+
+	if seg.array {
+		a, ok := v.([]any)
+		if !ok {
+			return nil
+		}
+
+		for i, e := range a {
+			if seg.index != -1 && seg.index != i {
+				continue
+			}
+
+			if err := sel[1:].match(e, append(path, pathElem{index: i}), cb); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	m, ok := v.(map[string]any)
+	if !ok {
+		return nil
+	}
+
+	if !seg.any {
+		e, ok := m[seg.key]
+		if !ok {
+			return nil
+		}
+
+		return sel[1:].match(e, append(path, pathElem{key: seg.key, index: -1}), cb)
+	}
+
+	for k, e := range m {
+		if err := sel[1:].match(e, append(path, pathElem{key: k, index: -1}), cb); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// correlated returns the first value matching selector within elements of path
+// that are shared with the other selector.
+func (sel selector) correlated(doc any, other selector, path []pathElem) string {
+	common := 0
+	for common < len(sel) && common < len(other) && sel[common] == other[common] {
+		common++
+	}
+
+	// Descend along concrete path of common segments.
+	v := doc
+
+	for _, p := range path[:common] {
+		switch t := v.(type) {
+		case []any:
+			v = t[p.index]
+		case map[string]any:
+			v = t[p.key]
+		}
+	}
+
+	var res string
+
+	_ = sel[common:].match(v, nil, func(_ []pathElem, v any) error {
+		if a, ok := v.([]any); ok {
+			parts := make([]string, 0, len(a))
+			for _, e := range a {
+				parts = append(parts, scalarString(e))
+			}
+
+			res = strings.Join(parts, ",")
+		} else {
+			res = scalarString(v)
+		}
+
+		return errStop
+	})
+
+	return res
+}
+
+var errStop = errors.New("stop")
+
+func scalarString(v any) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case json.Number:
+		return t.String()
+	case bool:
+		return strconv.FormatBool(t)
+	default:
+		return ""
+	}
+}
+
+func formatPath(path []pathElem) string {
+	var sb strings.Builder
+
+	for _, p := range path {
+		if p.index != -1 {
+			sb.WriteString("[" + strconv.Itoa(p.index) + "]")
+
+			continue
+		}
+
+		if sb.Len() > 0 {
+			sb.WriteByte('.')
+		}
+
+		sb.WriteString(p.key)
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,84 @@
+package lists_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vearutop/netrie"
+	"github.com/vearutop/netrie/lists"
+)
+
+func TestLoadFromJSONSelector(t *testing.T) {
+	tr := netrie.NewCIDRIndex()
+
+	require.NoError(t, lists.LoadFromJSONSelector("testdata/cloud/aws-ip-ranges.json", tr, func(o *lists.JSONSelectorOptions) {
+		o.CIDR = "prefixes[*].ip_prefix"
+		o.NameSelectors = []string{"prefixes[*].region", "$.prefixes[*]['service']"}
+	}))
+
+	assert.Equal(t, "af-south-1:AMAZON", tr.Lookup("3.2.34.1"))
+	assert.Equal(t, "us-east-1:AMAZON", tr.Lookup("18.208.0.1")) // Last one wins.
+	assert.Equal(t, "GLOBAL:CLOUDFRONT", tr.Lookup("13.32.0.1"))
+	assert.Equal(t, "", tr.Lookup("2600:1f14::1"))
+}
+
+func TestLoadFromJSONSelector_nested(t *testing.T) {
+	tr := netrie.NewCIDRIndex()
+
+	require.NoError(t, lists.LoadFromJSONSelector("testdata/cloud/oracle-public-ip-ranges.json", tr, func(o *lists.JSONSelectorOptions) {
+		o.CIDR = "regions[*].cidrs[*].cidr"
+		o.NameSelectors = []string{"regions[*].region", "regions[*].cidrs[*].tags"}
+		o.NameSeparator = "/"
+	}))
+
+	assert.Equal(t, 3, tr.Len())
+	assert.Equal(t, "us-phoenix-1/OCI", tr.Lookup("129.146.0.1"))
+	assert.Equal(t, "us-phoenix-1/OSN,OBJECT_STORAGE", tr.Lookup("134.70.8.1"))
+	assert.Equal(t, "eu-frankfurt-1/OCI", tr.Lookup("130.61.0.1"))
+
+	tr = netrie.NewCIDRIndex()
+
+	// Array of CIDRs is expanded, name is taken from parent object.
+	require.NoError(t, lists.LoadFromJSONSelector("testdata/cloud/azure-service-tags.json", tr, func(o *lists.JSONSelectorOptions) {
+		o.CIDR = "values[*].properties.addressPrefixes"
+		o.NameSelectors = []string{"values[*].name"}
+		o.MakeName = func(parts []string) string {
+			if parts[0] == "AzureCloud" {
+				return ""
+			}
+
+			return parts[0]
+		}
+	}))
+
+	assert.Equal(t, "AzureCloud.westus", tr.Lookup("13.64.0.1"))
+	assert.Equal(t, "AppService", tr.Lookup("13.64.73.110"))
+	assert.Equal(t, "", tr.Lookup("20.36.0.1"))
+
+	tr = netrie.NewCIDRIndex()
+
+	require.NoError(t, lists.LoadFromJSONSelector("testdata/cloud/cloudflare-ips.json", tr, func(o *lists.JSONSelectorOptions) {
+		o.CIDR = "result.*[*]"
+		o.Name = "cloudflare"
+	}))
+
+	assert.Equal(t, 5, tr.Len())
+	assert.Equal(t, "cloudflare", tr.Lookup("2606:4700::1111"))
+}
+
+func TestLoadFromJSONSelector_errors(t *testing.T) {
+	tr := netrie.NewCIDRIndex()
+
+	require.EqualError(t, lists.LoadFromJSONSelector("testdata/cloud/aws-ip-ranges.json", tr),
+		"CIDR selector is required")
+
+	require.EqualError(t, lists.LoadFromJSONSelector("testdata/cloud/aws-ip-ranges.json", tr, func(o *lists.JSONSelectorOptions) {
+		o.CIDR = "prefixes[x].ip_prefix"
+	}), `invalid selector "prefixes[x].ip_prefix": bad index "x"`)
+
+	require.EqualError(t, lists.LoadFromJSONSelector("testdata/cloud/aws-ip-ranges.json", tr, func(o *lists.JSONSelectorOptions) {
+		o.CIDR = "prefixes[*].service"
+		o.Name = "foo"
+	}), "prefixes[0].service: invalid CIDR address: AMAZON")
+}
@@ -0,0 +1,366 @@
+package lists
+
+import (
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// ErrMaxSizeExceeded is returned when content is larger than Source.MaxSize.
+var ErrMaxSizeExceeded = errors.New("max size exceeded")
+
+// Source is a file or HTTP(S) resource with list data.
+//
+// Content is decompressed automatically by file extension (.gz, .zst, .bz2) or HTTP content type.
+type Source struct {
+	URL string // File path or http(s) URL.
+
+	Client      *http.Client // HTTP client, default http.DefaultClient.
+	Header      http.Header  // Additional request headers.
+	BearerToken string       // Adds "Authorization: Bearer <token>" header.
+	Retries     int          // Number of retries for network errors and 5xx responses.
+
+	MaxSize int64 // Maximum size of downloaded and decompressed content in bytes, 0 for no limit.
+
+	// CacheDir enables caching of downloaded content in a local directory.
+	// Cached content is revalidated with ETag and If-Modified-Since and reused if not modified.
+	CacheDir string
+}
+
+// Open opens the source for reading, reader fails once ctx is done.
+func (s Source) Open(ctx context.Context) (io.ReadCloser, error) {
+	if !strings.HasPrefix(s.URL, "http://") && !strings.HasPrefix(s.URL, "https://") {
+		f, err := os.Open(s.URL)
+		if err != nil {
+			return nil, err
+		}
+
+		return s.wrap(ctx, f, compressionByName(s.URL))
+	}
+
+	return s.openHTTP(ctx)
+}
+
+// cacheMeta is stored next to cached content.
+type cacheMeta struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+	Compression  string `json:"compression,omitempty"`
+}
+
+func (s Source) openHTTP(ctx context.Context) (io.ReadCloser, error) {
+	var (
+		cacheFile string
+		meta      cacheMeta
+	)
+
+	if s.CacheDir != "" {
+		h := sha256.Sum256([]byte(s.URL))
+		cacheFile = filepath.Join(s.CacheDir, hex.EncodeToString(h[:]))
+
+		if b, err := os.ReadFile(cacheFile + ".json"); err == nil {
+			if err := json.Unmarshal(b, &meta); err != nil || meta.URL != s.URL {
+				meta = cacheMeta{}
+			}
+		}
+
+		// Validators are only sent if cached content is available.
+		if _, err := os.Stat(cacheFile); err != nil {
+			meta = cacheMeta{}
+		}
+	}
+
+	resp, err := s.do(ctx, meta)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified && meta.URL != "" {
+		_ = resp.Body.Close()
+
+		if f, err := os.Open(cacheFile); err == nil {
+			return s.wrap(ctx, f, meta.Compression)
+		}
+
+		// Cached content is not available anymore, it is fetched again without validators.
+		if resp, err = s.do(ctx, cacheMeta{}); err != nil {
+			return nil, err
+		}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+
+		return nil, fmt.Errorf("bad HTTP status code: %d", resp.StatusCode)
+	}
+
+	compression := compressionByName(resp.Request.URL.Path)
+	if compression == "" {
+		compression = compressionByResponse(resp)
+	}
+
+	if cacheFile == "" {
+		return s.wrap(ctx, resp.Body, compression)
+	}
+
+	defer resp.Body.Close()
+
+	if err := s.store(ctx, cacheFile, resp, compression); err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(cacheFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.wrap(ctx, f, compression)
+}
+
+// do sends request with retries.
+func (s Source) do(ctx context.Context, meta cacheMeta) (*http.Response, error) {
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	backoff := 100 * time.Millisecond
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		for k, v := range s.Header {
+			req.Header[k] = v
+		}
+
+		if s.BearerToken != "" {
+			req.Header.Set("Authorization", "Bearer "+s.BearerToken)
+		}
+
+		if meta.ETag != "" {
+			req.Header.Set("If-None-Match", meta.ETag)
+		}
+
+		if meta.LastModified != "" {
+			req.Header.Set("If-Modified-Since", meta.LastModified)
+		}
+
+		resp, err := client.Do(req)
+		if err == nil && resp.StatusCode < http.StatusInternalServerError {
+			return resp, nil
+		}
+
+		if attempt >= s.Retries || ctx.Err() != nil {
+			if err != nil {
+				return nil, err
+			}
+
+			return resp, nil
+		}
+
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+			backoff *= 2
+		}
+	}
+}
+
+// store saves response body and its validators to cache.
+func (s Source) store(ctx context.Context, cacheFile string, resp *http.Response, compression string) error {
+	if err := os.MkdirAll(s.CacheDir, 0o700); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(s.CacheDir, filepath.Base(cacheFile)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+
+	var body io.Reader = &ctxReader{ctx: ctx, r: resp.Body}
+	if s.MaxSize > 0 {
+		body = &limitedReader{r: body, n: s.MaxSize}
+	}
+
+	if _, err := io.Copy(tmp, body); err != nil {
+		return fmt.Errorf("download %s: %w", s.URL, err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), cacheFile); err != nil {
+		return err
+	}
+
+	meta, err := json.Marshal(cacheMeta{
+		URL:          s.URL,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Compression:  compression,
+	})
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(cacheFile+".json", meta, 0o600)
+}
+
+// wrap adds decompression, size limit and cancellation to a reader.
+func (s Source) wrap(ctx context.Context, rc io.ReadCloser, compression string) (io.ReadCloser, error) {
+	var (
+		r       io.Reader = rc
+		closeFn           = rc.Close
+	)
+
+	switch compression {
+	case "gzip":
+		gr, err := gzip.NewReader(rc)
+		if err != nil {
+			_ = rc.Close()
+
+			return nil, fmt.Errorf("gzip: %w", err)
+		}
+
+		r = gr
+	case "zstd":
+		zr, err := zstd.NewReader(rc)
+		if err != nil {
+			_ = rc.Close()
+
+			return nil, fmt.Errorf("zstd: %w", err)
+		}
+
+		r = zr
+		closeFn = func() error {
+			zr.Close()
+
+			return rc.Close()
+		}
+	case "bzip2":
+		r = bzip2.NewReader(rc)
+	}
+
+	r = &ctxReader{ctx: ctx, r: r}
+
+	if s.MaxSize > 0 {
+		r = &limitedReader{r: r, n: s.MaxSize}
+	}
+
+	return readCloser{Reader: r, close: closeFn}, nil
+}
+
+func compressionByName(name string) string {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".gz", ".gzip":
+		return "gzip"
+	case ".zst", ".zstd":
+		return "zstd"
+	case ".bz2":
+		return "bzip2"
+	default:
+		return ""
+	}
+}
+
+func compressionByResponse(resp *http.Response) string {
+	if !resp.Uncompressed {
+		switch strings.ToLower(resp.Header.Get("Content-Encoding")) {
+		case "gzip", "x-gzip":
+			return "gzip"
+		case "zstd":
+			return "zstd"
+		}
+	}
+
+	ct, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+
+	switch ct {
+	case "application/gzip", "application/x-gzip":
+		return "gzip"
+	case "application/zstd":
+		return "zstd"
+	case "application/x-bzip2":
+		return "bzip2"
+	default:
+		return ""
+	}
+}
+
+type readCloser struct {
+	io.Reader
+	close func() error
+}
+
+func (r readCloser) Close() error {
+	return r.close()
+}
+
+// ctxReader fails reads once context is done.
+type ctxReader struct {
+	ctx context.Context //nolint:containedctx // Reader is bound to a context of loading.
+	r   io.Reader
+}
+
+func (r *ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return r.r.Read(p)
+}
+
+// limitedReader fails with ErrMaxSizeExceeded if more than n bytes are available.
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.n < 0 {
+		return 0, ErrMaxSizeExceeded
+	}
+
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+
+	if l.n < 0 {
+		return n + int(l.n), ErrMaxSizeExceeded
+	}
+
+	return n, err
+}
@@ -0,0 +1,190 @@
+package lists_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vearutop/netrie"
+	"github.com/vearutop/netrie/lists"
+)
+
+func ranges(t *testing.T) []byte {
+	t.Helper()
+
+	b, err := os.ReadFile("testdata/ranges.txt")
+	require.NoError(t, err)
+
+	return b
+}
+
+func assertRanges(t *testing.T, tr *netrie.CIDRIndex[int16]) {
+	t.Helper()
+
+	assert.Equal(t, 7, tr.Len())
+	assert.Equal(t, "net1", tr.Lookup("10.0.0.6"))
+	assert.Equal(t, "net1", tr.Lookup("10.0.3.100"))
+	assert.Equal(t, "", tr.Lookup("10.0.4.2"))
+}
+
+func TestSource_http(t *testing.T) {
+	plain := ranges(t)
+
+	var gz bytes.Buffer
+
+	gw := gzip.NewWriter(&gz)
+	_, err := gw.Write(plain)
+	require.NoError(t, err)
+	require.NoError(t, gw.Close())
+
+	zw, err := zstd.NewWriter(nil)
+	require.NoError(t, err)
+
+	zst := zw.EncodeAll(plain, nil)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" || r.Header.Get("X-Foo") != "bar" {
+			w.WriteHeader(http.StatusUnauthorized)
+
+			return
+		}
+
+		switch r.URL.Path {
+		case "/ranges.txt":
+			_, _ = w.Write(plain)
+		case "/ranges":
+			w.Header().Set("Content-Type", "application/gzip")
+			_, _ = w.Write(gz.Bytes())
+		case "/ranges.txt.zst":
+			_, _ = w.Write(zst)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	for _, p := range []string{"/ranges.txt", "/ranges", "/ranges.txt.zst"} {
+		t.Run(p, func(t *testing.T) {
+			tr := netrie.NewCIDRIndex()
+
+			require.NoError(t, lists.LoadFromTextContext(context.Background(), lists.Source{
+				URL:         srv.URL + p,
+				Header:      http.Header{"X-Foo": []string{"bar"}},
+				BearerToken: "secret",
+			}, tr, "net1"))
+
+			assertRanges(t, tr)
+		})
+	}
+
+	tr := netrie.NewCIDRIndex()
+
+	require.EqualError(t, lists.LoadFromTextContext(context.Background(), lists.Source{URL: srv.URL + "/ranges.txt"}, tr, "net1"),
+		"bad HTTP status code: 401")
+
+	require.ErrorIs(t, lists.LoadFromTextContext(context.Background(), lists.Source{
+		URL:         srv.URL + "/ranges",
+		Header:      http.Header{"X-Foo": []string{"bar"}},
+		BearerToken: "secret",
+		MaxSize:     50,
+	}, tr, "net1"), lists.ErrMaxSizeExceeded)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	require.ErrorIs(t, lists.LoadFromTextContext(ctx, lists.Source{URL: srv.URL + "/ranges.txt"}, tr, "net1"), context.Canceled)
+}
+
+func TestSource_file(t *testing.T) {
+	tr := netrie.NewCIDRIndex()
+
+	require.NoError(t, lists.LoadFromTextContext(context.Background(), lists.Source{URL: "testdata/ranges.txt.bz2"}, tr, "net1"))
+	assertRanges(t, tr)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	require.ErrorIs(t, lists.LoadFromTextContext(ctx, lists.Source{URL: "testdata/ranges.txt"}, tr, "net1"), context.Canceled)
+}
+
+func TestSource_cache(t *testing.T) {
+	plain := ranges(t)
+
+	var requests, notModified, failures atomic.Int64
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+
+		if failures.Load() > 0 {
+			failures.Add(-1)
+			w.WriteHeader(http.StatusServiceUnavailable)
+
+			return
+		}
+
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+
+			return
+		}
+
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", "Tue, 16 Jan 2024 00:00:00 GMT")
+		_, _ = w.Write(plain)
+	}))
+	defer srv.Close()
+
+	src := lists.Source{
+		URL:      srv.URL + "/ranges.txt",
+		CacheDir: t.TempDir(),
+		Retries:  2,
+	}
+
+	for i := 0; i < 3; i++ {
+		tr := netrie.NewCIDRIndex()
+
+		require.NoError(t, lists.LoadFromTextContext(context.Background(), src, tr, "net1"))
+		assertRanges(t, tr)
+	}
+
+	assert.Equal(t, int64(3), requests.Load())
+	assert.Equal(t, int64(2), notModified.Load())
+
+	// Content is fetched again if cached body is removed and its metadata is kept.
+	meta, err := filepath.Glob(filepath.Join(src.CacheDir, "*.json"))
+	require.NoError(t, err)
+	require.Len(t, meta, 1)
+
+	body := strings.TrimSuffix(meta[0], ".json")
+	require.NoError(t, os.Remove(body))
+
+	tr := netrie.NewCIDRIndex()
+	require.NoError(t, lists.LoadFromTextContext(context.Background(), src, tr, "net1"))
+	assertRanges(t, tr)
+	assert.Equal(t, int64(4), requests.Load())
+	assert.Equal(t, int64(2), notModified.Load())
+	assert.FileExists(t, body)
+
+	// Server errors are retried.
+	failures.Store(2)
+
+	tr = netrie.NewCIDRIndex()
+
+	require.NoError(t, lists.LoadFromTextContext(context.Background(), src, tr, "net1"))
+	assertRanges(t, tr)
+	assert.Equal(t, int64(7), requests.Load())
+
+	failures.Store(3)
+	require.EqualError(t, lists.LoadFromTextContext(context.Background(), src, tr, "net1"), "bad HTTP status code: 503")
+}
@@ -0,0 +1,147 @@
+package netrie
+
+import (
+	"os"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// MemoryUsage describes memory used by an index in bytes.
+type MemoryUsage struct {
+	Nodes    int64 `json:"nodes"`      // Trie nodes.
+	Names    int64 `json:"names"`      // Names with string headers.
+	IDByName int64 `json:"id_by_name"` // Estimated size of name to id map, name bytes are shared with Names.
+	Cache    int64 `json:"cache"`      // Read buffer, decompressed blocks and names cache of file-backed index.
+
+	// FileResident is the number of bytes of index file that are resident in OS page cache,
+	// it is not included in Total as page cache is shared and can be reclaimed by OS.
+	FileResident int64 `json:"file_resident,omitempty"`
+}
+
+// Total returns memory used by the process for the index in bytes.
+func (m MemoryUsage) Total() int64 {
+	return m.Nodes + m.Names + m.IDByName + m.Cache
+}
+
+// MemoryUsageProvider is implemented by indexes that report their memory usage.
+type MemoryUsageProvider interface {
+	MemoryUsage() MemoryUsage
+}
+
+// MemoryUsage returns memory used by nodes, names and name to id map.
+func (idx *CIDRIndex[S]) MemoryUsage() MemoryUsage {
+	var s S
+
+	return MemoryUsage{
+		Nodes:    nodesSize(idx.nodes),
+		Names:    namesSize(idx.names),
+		IDByName: mapSize(len(idx.idByName), int64(unsafe.Sizeof("")+unsafe.Sizeof(s))),
+	}
+}
+
+// MemoryUsage returns memory used by names and caches, and the number of bytes of the file in page cache.
+// Number of bytes in page cache is computed without blocking lookups and is reused for a second.
+func (idx *CIDRIndexFile[S]) MemoryUsage() MemoryUsage {
+	m := idx.memoryUsage()
+
+	if f, ok := idx.src.r.(*os.File); ok {
+		m.FileResident = idx.resident.get(f)
+	}
+
+	return m
+}
+
+func (idx *CIDRIndexFile[S]) memoryUsage() MemoryUsage {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	m := MemoryUsage{
+		Names: namesSize(idx.names),
+		Cache: idx.cache.size(),
+	}
+
+	if br, ok := idx.r.(*bufReaderAt); ok {
+		m.Cache += int64(cap(br.buf))
+	}
+
+	if br, ok := idx.r.(*blockReaderAt); ok {
+		m.Cache += br.size()
+	}
+
+	return m
+}
+
+// residentTTL is a duration to reuse the number of bytes of the file in page cache.
+const residentTTL = time.Second
+
+// residentCache keeps the recent number of bytes of the file in page cache, as computing it maps the whole file.
+type residentCache struct {
+	mu    sync.Mutex
+	at    time.Time
+	bytes int64
+}
+
+func (rc *residentCache) get(f *os.File) int64 {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if time.Since(rc.at) >= residentTTL {
+		rc.bytes = fileResident(f)
+		rc.at = time.Now()
+	}
+
+	return rc.bytes
+}
+
+func nodesSize[S int16 | int32](nodes []trieNode[S]) int64 {
+	return int64(cap(nodes)) * int64(unsafe.Sizeof(trieNode[S]{}))
+}
+
+// namesSize returns the size of names slice with string headers and bytes.
+func namesSize(names []string) int64 {
+	size := int64(cap(names)) * int64(unsafe.Sizeof(""))
+
+	for _, name := range names {
+		size += int64(len(name))
+	}
+
+	return size
+}
+
+// mapSize estimates memory of a map with n entries of slotSize bytes,
+// map is organized in groups of 8 slots with a control word and load factor up to 7/8.
+func mapSize(n int, slotSize int64) int64 {
+	if n == 0 {
+		return 0
+	}
+
+	groups := int64(1)
+	for groups*7 < int64(n) {
+		groups <<= 1
+	}
+
+	return groups * (8 + 8*slotSize)
+}
+
+// size returns memory used by cached names.
+func (c *nameCache) size() int64 {
+	if c == nil {
+		return 0
+	}
+
+	return int64(cap(c.ids))*8 + namesSize(c.names)
+}
+
+// size returns memory used by decompressed blocks.
+func (br *blockReaderAt) size() int64 {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+
+	size := int64(0)
+	for _, cb := range br.cached {
+		size += int64(cap(cb.data))
+	}
+
+	return size
+}
@@ -0,0 +1,92 @@
+package netrie_test
+
+import (
+	"bytes"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vearutop/netrie"
+)
+
+func TestCIDRIndex_MemoryUsage(t *testing.T) {
+	idx := netrie.NewCIDRIndex()
+	assert.Equal(t, int64(0), idx.MemoryUsage().IDByName)
+
+	require.NoError(t, idx.AddCIDR("10.0.0.0/8", "foo"))
+	require.NoError(t, idx.AddCIDR("192.168.0.0/16", "bar"))
+
+	m := idx.MemoryUsage()
+	assert.GreaterOrEqual(t, m.Nodes, int64(idx.LenNodes()*12))
+	assert.GreaterOrEqual(t, m.Names, int64(2*16+6))
+	assert.Positive(t, m.IDByName)
+	assert.Equal(t, int64(0), m.Cache)
+	assert.Equal(t, m.Nodes+m.Names+m.IDByName, m.Total())
+
+	// Minimize releases nodes.
+	idx.Minimize()
+	assert.Less(t, idx.MemoryUsage().Nodes, m.Nodes)
+}
+
+func TestCIDRIndexFile_MemoryUsage(t *testing.T) {
+	idx := netrie.NewCIDRIndex()
+	require.NoError(t, idx.AddCIDR("10.0.0.0/8", "foo"))
+	require.NoError(t, idx.AddCIDR("192.168.0.0/16", "bar"))
+
+	fn := filepath.Join(t.TempDir(), "idx.bin")
+	require.NoError(t, idx.SaveCompressedToFile(fn, func(o *netrie.SaveOptions) {
+		o.Compression = netrie.CompressionNone
+		o.NamesOffsets = true
+	}))
+
+	l, err := netrie.OpenFile(fn, func(o *netrie.Options) { o.BufferSize = 1024 })
+	require.NoError(t, err)
+
+	defer l.Close()
+
+	mp, ok := l.(netrie.MemoryUsageProvider)
+	require.True(t, ok)
+
+	before := mp.MemoryUsage()
+	assert.Equal(t, int64(0), before.Nodes)
+	assert.Equal(t, int64(0), before.IDByName)
+
+	assert.Equal(t, "foo", l.Lookup("10.1.2.3"))
+
+	// Names are read on demand and cached.
+	m := mp.MemoryUsage()
+	assert.Greater(t, m.Cache, before.Cache)
+	assert.Equal(t, m.Names+m.Cache, m.Total())
+
+	if runtime.GOOS == "linux" {
+		assert.Positive(t, m.FileResident)
+	}
+
+	// Memory usage is safe to collect concurrently with lookups.
+	var wg sync.WaitGroup
+
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			assert.Equal(t, m.FileResident, mp.MemoryUsage().FileResident)
+			assert.Equal(t, "bar", l.Lookup("192.168.1.1"))
+		}()
+	}
+
+	wg.Wait()
+
+	// Memory-backed index has no file pages.
+	var buf bytes.Buffer
+	require.NoError(t, idx.Save(&buf))
+
+	l2, err := netrie.Open(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(0), l2.(netrie.MemoryUsageProvider).MemoryUsage().FileResident)
+}
@@ -0,0 +1,189 @@
+package netrie_test
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"os"
+	"runtime"
+	"runtime/metrics"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vearutop/netrie"
+)
+
+func TestCIDRIndex_Minimize(t *testing.T) {
+	idx := netrie.NewCIDRIndex()
+	require.NoError(t, idx.AddCIDR("10.0.0.0/8", "a"))
+	require.NoError(t, idx.AddCIDR("10.1.0.0/16", "b"))
+	require.NoError(t, idx.AddCIDR("192.168.0.0/16", "c"))
+	require.NoError(t, idx.AddCIDR("192.169.0.0/16", "c"))
+
+	idx.Minimize()
+
+	assert.Equal(t, "b", idx.Lookup("10.1.2.3"))
+	assert.Equal(t, "a", idx.Lookup("10.2.3.4"))
+	assert.Equal(t, "c", idx.Lookup("192.168.1.1"))
+	assert.Equal(t, "c", idx.Lookup("192.169.1.1"))
+	assert.Equal(t, "", idx.Lookup("1.1.1.1"))
+}
+
+func TestCIDRIndex_Minimize_parallel(t *testing.T) {
+	idx := syntheticIndex(200000)
+
+	expected := make([]string, 0, 100000)
+	rnd := rand.New(rand.NewSource(2))
+
+	ips := make([]string, 0, cap(expected))
+	for range cap(ips) {
+		ips = append(ips, fmt.Sprintf("%d.%d.%d.%d", rnd.Intn(256), rnd.Intn(256), rnd.Intn(256), rnd.Intn(256)))
+	}
+
+	for _, ip := range ips {
+		expected = append(expected, idx.Lookup(ip))
+	}
+
+	single := syntheticIndex(200000)
+	single.Minimize(func(o *netrie.MinimizeOptions) { o.Workers = 1 })
+
+	nodes := idx.LenNodes()
+	idx.Minimize(func(o *netrie.MinimizeOptions) { o.Workers = 4 })
+
+	assert.Less(t, idx.LenNodes(), nodes)
+	assert.Equal(t, single.LenNodes(), idx.LenNodes())
+
+	for i, ip := range ips {
+		require.Equal(t, expected[i], idx.Lookup(ip), ip)
+		require.Equal(t, expected[i], single.Lookup(ip), ip)
+	}
+}
+
+// syntheticIndex creates an index of random IPv4 prefixes with country-like names.
+func syntheticIndex(prefixes int) *netrie.CIDRIndex[int16] {
+	rnd := rand.New(rand.NewSource(1))
+	idx := netrie.NewCIDRIndex()
+
+	for i := 0; i < prefixes; i++ {
+		ip := net.IPv4(byte(rnd.Intn(224)), byte(rnd.Intn(256)), byte(rnd.Intn(256)), 0)
+		mask := net.CIDRMask(16+rnd.Intn(9), 32)
+
+		idx.AddNet(&net.IPNet{IP: ip.Mask(mask), Mask: mask}, fmt.Sprintf("C%d", rnd.Intn(200)))
+	}
+
+	return idx
+}
+
+// BenchmarkCIDRIndex_Minimize reports time, peak heap growth and peak RSS of minimization of a synthetic trie.
+// Peak RSS is reported on Linux, it includes the trie that is minimized.
+// Run with -benchtime=1x, 10M prefixes need a few GB of memory to build the trie.
+func BenchmarkCIDRIndex_Minimize(b *testing.B) {
+	for _, prefixes := range []int{1e6, 10e6} {
+		for _, workers := range slices.Compact([]int{1, runtime.GOMAXPROCS(0)}) {
+			b.Run(fmt.Sprintf("prefixes=%d/workers=%d", prefixes, workers), func(b *testing.B) {
+				if prefixes > 1e6 && testing.Short() {
+					b.Skip("short mode")
+				}
+
+				var peak, rss uint64
+
+				for i := 0; i < b.N; i++ {
+					b.StopTimer()
+
+					idx := syntheticIndex(prefixes)
+					nodes := idx.LenNodes()
+
+					runtime.GC()
+					resetPeakRSS()
+
+					stop := sampleHeap()
+
+					b.StartTimer()
+
+					idx.Minimize(func(o *netrie.MinimizeOptions) { o.Workers = workers })
+
+					b.StopTimer()
+
+					peak = max(peak, stop())
+					rss = max(rss, peakRSS())
+
+					b.ReportMetric(float64(nodes), "nodes")
+					b.ReportMetric(float64(idx.LenNodes()), "min-nodes")
+				}
+
+				b.ReportMetric(float64(peak)/(1<<20), "peak-heap-MB")
+
+				if rss > 0 {
+					b.ReportMetric(float64(rss)/(1<<20), "peak-rss-MB")
+				}
+			})
+		}
+	}
+}
+
+// sampleHeap samples heap size until stop is called, stop returns the peak growth of heap.
+func sampleHeap() (stop func() uint64) {
+	sample := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	heap := func() uint64 {
+		metrics.Read(sample)
+
+		return sample[0].Value.Uint64()
+	}
+
+	base := heap()
+	peak := base
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(time.Millisecond)
+		defer ticker.Stop()
+
+		for {
+			peak = max(peak, heap())
+
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func() uint64 {
+		close(done)
+		<-stopped
+
+		return max(peak, heap()) - base
+	}
+}
+
+// resetPeakRSS resets peak resident set size of the process on Linux.
+func resetPeakRSS() {
+	_ = os.WriteFile("/proc/self/clear_refs", []byte("5"), 0)
+}
+
+// peakRSS returns peak resident set size of the process in bytes from /proc/self/status, 0 if not available.
+func peakRSS() uint64 {
+	status, err := os.ReadFile("/proc/self/status")
+	if err != nil {
+		return 0
+	}
+
+	for _, line := range strings.Split(string(status), "\n") {
+		if v, ok := strings.CutPrefix(line, "VmHWM:"); ok {
+			kb, _ := strconv.ParseUint(strings.TrimSpace(strings.TrimSuffix(v, "kB")), 10, 64)
+
+			return kb << 10
+		}
+	}
+
+	return 0
+}
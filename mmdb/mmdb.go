@@ -246,7 +246,9 @@ func Load(tr netrie.Adder, mmdbPath string, options ...func(o *Options)) error {
 					println(n.String(), prevName)
 				}
 
-				tr.AddNet(n, prevName)
+				if err := netrie.AddNet(tr, n, prevName); err != nil {
+					return err
+				}
 			}
 
 			nets = nets[:0]
@@ -265,7 +267,9 @@ func Load(tr netrie.Adder, mmdbPath string, options ...func(o *Options)) error {
 
 	merged := cidrmerge.Merge(nets)
 	for _, n := range merged {
-		tr.AddNet(n, prevName)
+		if err := netrie.AddNet(tr, n, prevName); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -0,0 +1,410 @@
+package netrie
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Header version flags of names section.
+const (
+	// flagFrontCodedNames indicates front-coded names section.
+	flagFrontCodedNames = 1 << 24
+
+	// flagNamesOffsets indicates names section with offsets table followed by concatenated names.
+	flagNamesOffsets = 1 << 25
+)
+
+// frontCodingBucket is a number of names in a front-coded bucket, first name of a bucket is stored in full.
+const frontCodingBucket = 16
+
+// maxFrontCodingBucket is a maximal number of names in a front-coded bucket accepted by readers.
+const maxFrontCodingBucket = 1024
+
+// sortedNames returns names in lexicographical order and a map of old id to new id.
+func sortedNames(names []string) ([]string, []int32) {
+	order := make([]int, len(names))
+	for i := range order {
+		order[i] = i
+	}
+
+	sort.Slice(order, func(i, j int) bool {
+		return names[order[i]] < names[order[j]]
+	})
+
+	sorted := make([]string, len(names))
+	newIDs := make([]int32, len(names)+1)
+
+	for i, o := range order {
+		sorted[i] = names[o]
+		newIDs[o+1] = int32(i + 1)
+	}
+
+	return sorted, newIDs
+}
+
+// writeFrontCoded writes bucket size (uint32), number of buckets (uint32),
+// offsets of buckets (uint32, number of buckets + 1) relative to the first bucket and buckets.
+//
+// Bucket starts with the full name: length (uvarint) and bytes, following names are stored as
+// length of a prefix shared with the previous name (uvarint), length of suffix (uvarint) and suffix bytes.
+func writeFrontCoded(w io.Writer, names []string) error {
+	bucketsLen := (len(names) + frontCodingBucket - 1) / frontCodingBucket
+
+	var data bytes.Buffer
+
+	offsets := make([]byte, 8+4*(bucketsLen+1))
+	binary.BigEndian.PutUint32(offsets[0:4], frontCodingBucket)
+	binary.BigEndian.PutUint32(offsets[4:8], uint32(bucketsLen))
+
+	prev := ""
+
+	for i, name := range names {
+		if i%frontCodingBucket == 0 {
+			binary.BigEndian.PutUint32(offsets[8+4*(i/frontCodingBucket):], uint32(data.Len()))
+			data.Write(binary.AppendUvarint(nil, uint64(len(name))))
+			data.WriteString(name)
+			prev = name
+
+			continue
+		}
+
+		shared := 0
+		for shared < len(prev) && shared < len(name) && prev[shared] == name[shared] {
+			shared++
+		}
+
+		data.Write(binary.AppendUvarint(nil, uint64(shared)))
+		data.Write(binary.AppendUvarint(nil, uint64(len(name)-shared)))
+		data.WriteString(name[shared:])
+		prev = name
+	}
+
+	binary.BigEndian.PutUint32(offsets[8+4*bucketsLen:], uint32(data.Len()))
+
+	if _, err := w.Write(offsets); err != nil {
+		return fmt.Errorf("failed to write names index: %w", err)
+	}
+
+	if _, err := w.Write(data.Bytes()); err != nil {
+		return fmt.Errorf("failed to write names: %w", err)
+	}
+
+	return nil
+}
+
+// frontCodedIndex is a header of front-coded names section.
+type frontCodedIndex struct {
+	bucketSize int
+	offsets    []uint32
+}
+
+// readFrontCodedHeader reads and validates bucket size and number of buckets of namesLen names.
+func readFrontCodedHeader(namesLen int, read func(b []byte) error) (bucketSize, bucketsLen int, err error) {
+	hdr := make([]byte, 8)
+	if err := read(hdr); err != nil {
+		return 0, 0, fmt.Errorf("read names index: %w", err)
+	}
+
+	bucketSize = int(binary.BigEndian.Uint32(hdr[0:4]))
+	bucketsLen = int(binary.BigEndian.Uint32(hdr[4:8]))
+
+	if bucketSize <= 0 || bucketSize > maxFrontCodingBucket || bucketsLen != (namesLen+bucketSize-1)/bucketSize {
+		return 0, 0, fmt.Errorf("invalid names index: %d buckets of %d for %d names", bucketsLen, bucketSize, namesLen)
+	}
+
+	return bucketSize, bucketsLen, nil
+}
+
+// readFrontCodedIndex reads bucket offsets of namesLen names from r.
+func readFrontCodedIndex(namesLen int, r io.Reader) (frontCodedIndex, error) {
+	fi := frontCodedIndex{}
+
+	bucketSize, bucketsLen, err := readFrontCodedHeader(namesLen, func(b []byte) error {
+		_, err := io.ReadFull(r, b)
+
+		return err
+	})
+	if err != nil {
+		return fi, err
+	}
+
+	fi.bucketSize = bucketSize
+
+	b, err := readFull(r, 4*int64(bucketsLen+1))
+	if err != nil {
+		return fi, fmt.Errorf("read names index: %w", err)
+	}
+
+	fi.offsets = make([]uint32, bucketsLen+1)
+
+	for i := range fi.offsets {
+		fi.offsets[i] = binary.BigEndian.Uint32(b[4*i:])
+
+		if i > 0 && fi.offsets[i] < fi.offsets[i-1] {
+			return fi, fmt.Errorf("invalid names index: offset %d decreases", i)
+		}
+	}
+
+	return fi, nil
+}
+
+// size returns the size of the section after the index.
+func (fi frontCodedIndex) size() int64 {
+	return int64(fi.offsets[len(fi.offsets)-1])
+}
+
+var errBadBucket = errors.New("malformed names bucket")
+
+// decodeBucket calls fn for up to n names of the bucket until it returns false.
+func decodeBucket(b []byte, n int, fn func(i int, name []byte) bool) error {
+	var name []byte
+
+	for i := 0; i < n; i++ {
+		shared := uint64(0)
+
+		if i > 0 {
+			v, l := binary.Uvarint(b)
+			if l <= 0 || v > uint64(len(name)) {
+				return errBadBucket
+			}
+
+			shared = v
+			b = b[l:]
+		}
+
+		suffix, l := binary.Uvarint(b)
+		if l <= 0 || suffix > uint64(len(b)-l) {
+			return errBadBucket
+		}
+
+		b = b[l:]
+		name = append(name[:shared], b[:suffix]...)
+		b = b[suffix:]
+
+		if !fn(i, name) {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// loadFrontCoded reads front-coded names section.
+func (idx *CIDRIndex[S]) loadFrontCoded(h hd, r io.Reader, o Options) error {
+	namesLen := int(h.namesLen)
+
+	fi, err := readFrontCodedIndex(namesLen, r)
+	if err != nil {
+		return err
+	}
+
+	data, err := readFull(r, fi.size())
+	if err != nil {
+		return fmt.Errorf("read names: %w", err)
+	}
+
+	idx.names = make([]string, 0, min(namesLen, loadChunk))
+
+	for bucket := range len(fi.offsets) - 1 {
+		first := bucket * fi.bucketSize
+
+		var nameErr error
+
+		err := decodeBucket(data[fi.offsets[bucket]:fi.offsets[bucket+1]], fi.bucketLen(bucket, namesLen),
+			func(i int, name []byte) bool {
+				if nameErr = o.checkNameLen(first+i, int64(len(name))); nameErr != nil {
+					return false
+				}
+
+				idx.names = append(idx.names, string(name))
+				idx.idByName[idx.names[first+i]] = S(first + i + 1)
+
+				return true
+			})
+		if err != nil {
+			return fmt.Errorf("read names bucket %d: %w", bucket, err)
+		}
+
+		if nameErr != nil {
+			return nameErr
+		}
+	}
+
+	return nil
+}
+
+// bucketLen returns the number of names in the bucket.
+func (fi frontCodedIndex) bucketLen(bucket, namesLen int) int {
+	return min(fi.bucketSize, namesLen-bucket*fi.bucketSize)
+}
+
+// frontCodedName reads offsets of a bucket and decodes a name by its 0-based position, idx.mu must be held.
+func (idx *CIDRIndexFile[S]) frontCodedName(pos int) (string, error) {
+	bucket := pos / idx.bucketSize
+
+	offsets := make([]byte, 8)
+	if n, err := idx.r.ReadAt(offsets, idx.namesOffset+8+4*int64(bucket)); n < len(offsets) {
+		return "", fmt.Errorf("read names bucket %d offsets: %w", bucket, err)
+	}
+
+	start, end := binary.BigEndian.Uint32(offsets[0:4]), binary.BigEndian.Uint32(offsets[4:8])
+	if start > end {
+		return "", fmt.Errorf("invalid names bucket %d offsets: %d-%d", bucket, start, end)
+	}
+
+	// Each name has at most two uvarint lengths and name bytes.
+	if maxLen := int64(idx.bucketSize) * (idx.maxNameLen + 2*binary.MaxVarintLen32); int64(end-start) > maxLen {
+		return "", fmt.Errorf("%w: names bucket %d of %d bytes, max %d", ErrLimitExceeded, bucket, end-start, maxLen)
+	}
+
+	data, err := readFull(io.NewSectionReader(idx.r, idx.namesDataOffset+int64(start), int64(end-start)), int64(end-start))
+	if err != nil {
+		return "", fmt.Errorf("read names bucket %d: %w", bucket, err)
+	}
+
+	var name string
+
+	err = decodeBucket(data, pos%idx.bucketSize+1, func(i int, b []byte) bool {
+		if i == pos%idx.bucketSize {
+			name = string(b)
+		}
+
+		return true
+	})
+	if err != nil {
+		return "", fmt.Errorf("read names bucket %d: %w", bucket, err)
+	}
+
+	return name, nil
+}
+
+// writeIndexedNames writes offsets of names (uint32, number of names + 1) relative to the first name,
+// followed by concatenated names.
+func writeIndexedNames(w io.Writer, names []string) error {
+	offsets := make([]byte, 4*(len(names)+1))
+	offset := uint32(0)
+
+	for i, name := range names {
+		binary.BigEndian.PutUint32(offsets[4*i:], offset)
+		offset += uint32(len(name))
+	}
+
+	binary.BigEndian.PutUint32(offsets[4*len(names):], offset)
+
+	if _, err := w.Write(offsets); err != nil {
+		return fmt.Errorf("failed to write names offsets: %w", err)
+	}
+
+	for i, name := range names {
+		if _, err := io.WriteString(w, name); err != nil {
+			return fmt.Errorf("failed to write name %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// loadIndexedNames reads names section with offsets table.
+func (idx *CIDRIndex[S]) loadIndexedNames(h hd, r io.Reader, o Options) error {
+	namesLen := int(h.namesLen)
+
+	offsets, err := readFull(r, 4*int64(namesLen+1))
+	if err != nil {
+		return fmt.Errorf("read names offsets: %w", err)
+	}
+
+	size := binary.BigEndian.Uint32(offsets[4*namesLen:])
+
+	data, err := readFull(r, int64(size))
+	if err != nil {
+		return fmt.Errorf("read names: %w", err)
+	}
+
+	idx.names = make([]string, namesLen)
+
+	for i := range idx.names {
+		start, end := binary.BigEndian.Uint32(offsets[4*i:]), binary.BigEndian.Uint32(offsets[4*i+4:])
+		if start > end || end > size {
+			return fmt.Errorf("invalid name %d offsets: %d-%d", i, start, end)
+		}
+
+		if err := o.checkNameLen(i, int64(end-start)); err != nil {
+			return err
+		}
+
+		idx.names[i] = string(data[start:end])
+		idx.idByName[idx.names[i]] = S(i + 1)
+	}
+
+	return nil
+}
+
+// indexedName reads offsets and bytes of a name by its 0-based position, idx.mu must be held.
+func (idx *CIDRIndexFile[S]) indexedName(pos int) (string, error) {
+	offsets := make([]byte, 8)
+	if n, err := idx.r.ReadAt(offsets, idx.namesOffset+4*int64(pos)); n < len(offsets) {
+		return "", fmt.Errorf("read name %d offsets: %w", pos, err)
+	}
+
+	start, end := binary.BigEndian.Uint32(offsets[0:4]), binary.BigEndian.Uint32(offsets[4:8])
+	if start > end {
+		return "", fmt.Errorf("invalid name %d offsets: %d-%d", pos, start, end)
+	}
+
+	if int64(end-start) > idx.maxNameLen {
+		return "", fmt.Errorf("%w: name %d of %d bytes, max %d", ErrLimitExceeded, pos, end-start, idx.maxNameLen)
+	}
+
+	data := make([]byte, end-start)
+	if n, err := idx.r.ReadAt(data, idx.namesDataOffset+int64(start)); n < len(data) {
+		return "", fmt.Errorf("read name %d: %w", pos, err)
+	}
+
+	return string(data), nil
+}
+
+// nameCache is a direct-mapped cache of recently used names.
+type nameCache struct {
+	ids   []int64
+	names []string
+}
+
+func newNameCache(size int) *nameCache {
+	if size <= 0 {
+		return nil
+	}
+
+	c := &nameCache{ids: make([]int64, size), names: make([]string, size)}
+	for i := range c.ids {
+		c.ids[i] = -1
+	}
+
+	return c
+}
+
+func (c *nameCache) get(id int64) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+
+	i := id % int64(len(c.ids))
+	if c.ids[i] != id {
+		return "", false
+	}
+
+	return c.names[i], true
+}
+
+func (c *nameCache) put(id int64, name string) {
+	if c == nil {
+		return
+	}
+
+	i := id % int64(len(c.ids))
+	c.ids[i] = id
+	c.names[i] = name
+}
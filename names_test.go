@@ -0,0 +1,206 @@
+package netrie_test
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vearutop/netrie"
+)
+
+func TestCIDRIndex_SaveCompressed_frontCodedNames(t *testing.T) {
+	idx := netrie.NewCIDRIndex()
+
+	for i := 0; i < 1000; i++ {
+		_, n, err := net.ParseCIDR(fmt.Sprintf("10.%d.%d.0/24", i/256, i%256))
+		require.NoError(t, err)
+
+		idx.AddNet(n, fmt.Sprintf("organization-%03d:datacenter-%d", 999-i, i%7))
+	}
+
+	plain := bytes.NewBuffer(nil)
+	require.NoError(t, idx.Save(plain))
+
+	fc := bytes.NewBuffer(nil)
+	require.NoError(t, idx.SaveCompressed(fc, func(o *netrie.SaveOptions) {
+		o.Compression = netrie.CompressionNone
+		o.FrontCodedNames = true
+	}))
+
+	assert.Less(t, fc.Len(), plain.Len()*3/4)
+
+	check := func(t *testing.T, l netrie.IPLookuper) {
+		t.Helper()
+
+		assert.Equal(t, idx.Len(), l.Len())
+		assert.Equal(t, idx.LenNames(), l.LenNames())
+
+		for _, i := range []int{0, 1, 15, 16, 17, 500, 998, 999} {
+			ip := fmt.Sprintf("10.%d.%d.1", i/256, i%256)
+			assert.Equal(t, fmt.Sprintf("organization-%03d:datacenter-%d", 999-i, i%7), l.Lookup(ip), ip)
+		}
+
+		assert.Equal(t, "", l.Lookup("11.0.0.1"))
+	}
+
+	l, err := netrie.Load(bytes.NewReader(fc.Bytes()))
+	require.NoError(t, err)
+	check(t, l)
+
+	// Loaded names keep their ids.
+	loaded, ok := l.(*netrie.CIDRIndex[int16])
+	require.True(t, ok)
+
+	_, n, err := net.ParseCIDR("11.0.0.0/8")
+	require.NoError(t, err)
+	loaded.AddNet(n, "organization-001:datacenter-4")
+	assert.Equal(t, idx.LenNames(), loaded.LenNames())
+	assert.Equal(t, "organization-001:datacenter-4", loaded.Lookup("11.0.0.1"))
+
+	f, err := netrie.Open(bytes.NewReader(fc.Bytes()))
+	require.NoError(t, err)
+	check(t, f)
+
+	// Front-coded names in seekable compressed format.
+	fn := filepath.Join(t.TempDir(), "names.bin.zst")
+	require.NoError(t, idx.SaveCompressedToFile(fn, func(o *netrie.SaveOptions) {
+		o.BlockSize = 1024
+		o.FrontCodedNames = true
+	}))
+
+	l, err = netrie.LoadFromFile(fn)
+	require.NoError(t, err)
+	check(t, l)
+
+	f, err = netrie.OpenFile(fn)
+	require.NoError(t, err)
+	check(t, f)
+	require.NoError(t, f.Close())
+}
+
+func TestOpen_frontCodedNames(t *testing.T) {
+	idx := loadCities(t)
+
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, idx.SaveCompressed(buf, func(o *netrie.SaveOptions) {
+		o.Compression = netrie.CompressionNone
+		o.FrontCodedNames = true
+	}))
+
+	trf, err := netrie.Open(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	assertCities(t, trf)
+
+	// Unknown flags are rejected.
+	b := buf.Bytes()
+	b[0] |= 0x04
+
+	_, err = netrie.Open(bytes.NewReader(b))
+	require.EqualError(t, err, "unmarshal header: unsupported flags: 0x4000000")
+}
+
+func TestSave_legacyNames(t *testing.T) {
+	idx := netrie.NewCIDRIndex()
+	require.NoError(t, idx.AddCIDR("10.0.0.0/8", "foo"))
+
+	// Plain Save keeps names layout of earlier releases, header has no names flags.
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, idx.Save(buf))
+	assert.Equal(t, []byte{0, 0, 0, 1}, buf.Bytes()[:4])
+
+	f, err := netrie.Open(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, "foo", f.Lookup("10.1.2.3"))
+
+	require.EqualError(t, idx.Save(buf, func(o *netrie.SaveOptions) {
+		o.FrontCodedNames = true
+		o.NamesOffsets = true
+	}), "FrontCodedNames and NamesOffsets are mutually exclusive")
+
+	// Names offsets table with plain file.
+	fn := filepath.Join(t.TempDir(), "networks.bin")
+	require.NoError(t, idx.SaveToFile(fn, func(o *netrie.SaveOptions) { o.NamesOffsets = true }))
+
+	f, err = netrie.OpenFile(fn)
+	require.NoError(t, err)
+
+	defer f.Close()
+
+	assert.Equal(t, "foo", f.Lookup("10.1.2.3"))
+
+	data, err := os.ReadFile(fn)
+	require.NoError(t, err)
+	assert.NotEqual(t, []byte{0, 0, 0, 1}, data[:4])
+	assert.Equal(t, byte(1), data[3])
+}
+
+func TestOpen_namesOffsets(t *testing.T) {
+	idx := netrie.NewCIDRLargeIndex()
+
+	for i := 0; i < 50000; i++ {
+		_, n, err := net.ParseCIDR(fmt.Sprintf("10.%d.%d.0/24", i/256, i%256))
+		require.NoError(t, err)
+
+		idx.AddNet(n, fmt.Sprintf("name-%d", i))
+	}
+
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, idx.Save(buf, func(o *netrie.SaveOptions) {
+		o.NamesOffsets = true
+	}))
+
+	// Same as uncompressed SaveCompressed.
+	compressed := bytes.NewBuffer(nil)
+	require.NoError(t, idx.SaveCompressed(compressed, func(o *netrie.SaveOptions) {
+		o.Compression = netrie.CompressionNone
+		o.NamesOffsets = true
+	}))
+	require.Equal(t, compressed.Bytes(), buf.Bytes())
+
+	reads := func(f netrie.IPLookuper) int64 {
+		return f.(netrie.ReadStatsProvider).ReadStats().Reads
+	}
+
+	for _, nameCache := range []int{0, 16} {
+		t.Run(fmt.Sprintf("cache_%d", nameCache), func(t *testing.T) {
+			f, err := netrie.Open(bytes.NewReader(buf.Bytes()), func(o *netrie.Options) {
+				o.BufferSize = 0
+				o.NameCache = nameCache
+			})
+			require.NoError(t, err)
+
+			// Header and metadata only, names are not read at open time.
+			assert.Equal(t, int64(2), reads(f))
+			assert.Equal(t, 50000, f.LenNames())
+
+			before := reads(f)
+			assert.Equal(t, "name-12345", f.Lookup("10.48.57.1"))
+			first := reads(f) - before
+
+			before = reads(f)
+			assert.Equal(t, "name-12345", f.Lookup("10.48.57.1"))
+			second := reads(f) - before
+
+			if nameCache > 0 {
+				// Offsets and name bytes are not read again.
+				assert.Equal(t, first-2, second)
+			} else {
+				assert.Equal(t, first, second)
+			}
+
+			assert.Equal(t, "name-0", f.Lookup("10.0.0.1"))
+			assert.Equal(t, "name-49999", f.Lookup("10.195.79.1"))
+			assert.Equal(t, "", f.Lookup("11.0.0.1"))
+		})
+	}
+
+	l, err := netrie.Load(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, "name-12345", l.Lookup("10.48.57.1"))
+	assert.Equal(t, 50000, l.LenNames())
+}
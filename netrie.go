@@ -1,10 +1,24 @@
 package netrie
 
 import (
+	"errors"
+	"fmt"
 	"net"
+	"net/netip"
 	"time"
 )
 
+// ErrTooManyNames is returned when a name does not fit in the namespace of an index,
+// use NewCIDRLargeIndex or ToLarge for more than 2^15-1 names.
+var ErrTooManyNames = errors.New("too many names")
+
+// match is the result of a trie traversal.
+type match[S int16 | int32] struct {
+	id      S   // Name id of the longest matched CIDR, -1 if none.
+	maskLen int // Mask length of the longest matched CIDR, -1 if none.
+	bits    int // Number of leading address bits that determine the result.
+}
+
 // trieNode represents a node in the CIDR trie.
 type trieNode[S int16 | int32] struct {
 	children [2]int32 // Indices of child nodes (0 or 1).
@@ -12,6 +26,15 @@ type trieNode[S int16 | int32] struct {
 	maskLen  int8     // Length of the CIDR mask, -1 if none.
 }
 
+// bits returns the length of the CIDR mask, -1 if none, /128 is stored as -128.
+func (n trieNode[S]) bits() int {
+	if n.maskLen == -1 {
+		return -1
+	}
+
+	return int(uint8(n.maskLen))
+}
+
 // Metadata represents additional information related to a structure or process.
 type Metadata struct {
 	BuildDate   time.Time `json:"build_date,omitzero"`
@@ -29,6 +52,12 @@ type CIDRIndex[S int16 | int32] struct {
 	total int
 
 	idByName map[string]S
+
+	// shared is set when nodes may have multiple parents after minimization.
+	shared bool
+
+	// err is the first error of AddNet.
+	err error
 }
 
 func newCIDRIndex[S int16 | int32]() *CIDRIndex[S] {
@@ -39,27 +68,111 @@ func newCIDRIndex[S int16 | int32]() *CIDRIndex[S] {
 }
 
 // AddNet inserts a CIDR block represented by ipNet into the trie, associating it with the specified name.
+// IPv4-mapped IPv6 block is inserted as IPv4 block.
+// If the block is invalid or the name does not fit in the namespace, the block is skipped
+// and the error (ErrTooManyNames for the namespace) is available with Err.
 func (idx *CIDRIndex[S]) AddNet(ipNet *net.IPNet, name string) {
+	if err := idx.add(ipNet, name); err != nil && idx.err == nil {
+		idx.err = err
+	}
+}
+
+// Err returns the first error that occurred in AddNet, an index with an error can not be saved.
+func (idx *CIDRIndex[S]) Err() error {
+	return idx.err
+}
+
+func (idx *CIDRIndex[S]) add(ipNet *net.IPNet, name string) error {
+	p, err := netPrefix(ipNet, name)
+	if err != nil {
+		return err
+	}
+
+	return idx.addPrefix(p, name)
+}
+
+func (idx *CIDRIndex[S]) addPrefix(p netip.Prefix, name string) error {
+	p, err := checkPrefix(p, name)
+	if err != nil {
+		return err
+	}
+
+	id, err := idx.nameID(name)
+	if err != nil {
+		return err
+	}
+
+	current, maskLen := idx.prefixNode(p), p.Bits()
+
+	// Set id and mask length at the leaf node.
+	idx.nodes[current].id = id
+	idx.nodes[current].maskLen = int8(maskLen)
+
+	idx.total++
+
+	return nil
+}
+
+// nameID returns id of the name, adding it if necessary.
+// Returns ErrTooManyNames if a new name does not fit in the namespace.
+func (idx *CIDRIndex[S]) nameID(name string) (S, error) {
 	id := idx.idByName[name]
 
 	if id == 0 {
-		idx.names = append(idx.names, name)
-		id = S(len(idx.names))
+		id = S(len(idx.names) + 1)
 
-		if int32(id) != int32(len(idx.names)) {
-			panic("too many names, use netrie.NewCIDRLargeIndex")
+		if int(id) != len(idx.names)+1 {
+			return 0, fmt.Errorf("%w: %q is name %d", ErrTooManyNames, name, len(idx.names)+1)
 		}
 
+		idx.names = append(idx.names, name)
 		idx.idByName[name] = id
 	}
 
-	// Get 16-byte IP representation (IPv4 or IPv6).
+	return id, nil
+}
+
+// netPrefix converts ipNet to a prefix, returns error if ipNet is invalid.
+func netPrefix(ipNet *net.IPNet, name string) (netip.Prefix, error) {
 	ip := ipNet.IP
-	if ip4 := ip.To4(); ip4 != nil {
-		ip = ip4 // Convert IPv4 to 4-byte representation.
+
+	// IPv4-mapped address with IPv6 mask is unmapped by checkPrefix.
+	if ip4 := ip.To4(); ip4 != nil && len(ipNet.Mask) == net.IPv4len {
+		ip = ip4
+	}
+
+	addr, ok := netip.AddrFromSlice(ip)
+	maskLen, bits := ipNet.Mask.Size()
+
+	if !ok || bits != 8*len(ip) {
+		return netip.Prefix{}, fmt.Errorf("invalid CIDR (%s): %v", name, ipNet)
+	}
+
+	return netip.PrefixFrom(addr, maskLen), nil
+}
+
+// checkPrefix returns masked prefix, IPv4-mapped IPv6 prefix is converted to IPv4 prefix.
+// Returns error if prefix is invalid or IPv4-mapped prefix has less than 96 bits.
+func checkPrefix(p netip.Prefix, name string) (netip.Prefix, error) {
+	if !p.IsValid() {
+		return netip.Prefix{}, fmt.Errorf("invalid prefix (%s): %v", name, p)
+	}
+
+	if p.Addr().Is4In6() {
+		if p.Bits() < 96 {
+			return netip.Prefix{}, fmt.Errorf("invalid IPv4-mapped prefix (%s): %v", name, p)
+		}
+
+		p = unmapPrefix(p)
 	}
 
-	maskLen, _ := ipNet.Mask.Size()
+	return p.Masked(), nil
+}
+
+// prefixNode returns the index of the node for prefix p, creating missing nodes on the path.
+func (idx *CIDRIndex[S]) prefixNode(p netip.Prefix) int {
+	ip := prefixBytes(p)
+	maskLen := p.Bits()
 	current := 0 // Start at root node.
 
 	// Traverse or build the trie for each bit in the mask.
@@ -75,15 +188,16 @@ func (idx *CIDRIndex[S]) AddNet(ipNet *net.IPNet, name string) {
 				maskLen:  -1,
 			})
 			childIndex = idx.nodes[current].children[bit]
+		} else if idx.shared {
+			// Shared node is copied before modification, previous copy may become unreachable until next Minimize.
+			idx.nodes[current].children[bit] = int32(len(idx.nodes))
+			idx.nodes = append(idx.nodes, idx.nodes[childIndex])
+			childIndex = idx.nodes[current].children[bit]
 		}
 		current = int(childIndex)
 	}
 
-	// Set id and mask length at the leaf node.
-	idx.nodes[current].id = id
-	idx.nodes[current].maskLen = int8(maskLen)
-
-	idx.total++
+	return current
 }
 
 // LookupIP finds the id of the CIDR that contains the given IP.
@@ -98,9 +212,38 @@ func (idx *CIDRIndex[S]) LookupIP(ip net.IP) string {
 		ip = ip4
 	}
 
+	return idx.name(idx.lookup(ip).id)
+}
+
+// lookupAddr finds the name of the CIDR that contains the given address.
+func (idx *CIDRIndex[S]) lookupAddr(addr netip.Addr) string {
+	return idx.name(idx.lookupAddrID(addr).id)
+}
+
+// lookupAddrID finds the match for the given address.
+func (idx *CIDRIndex[S]) lookupAddrID(addr netip.Addr) match[S] {
+	if !addr.IsValid() {
+		return match[S]{id: -1, maskLen: -1}
+	}
+
+	addr = addr.Unmap()
+
+	if addr.Is4() {
+		ip := addr.As4()
+
+		return idx.lookup(ip[:])
+	}
+
+	ip := addr.As16()
+
+	return idx.lookup(ip[:])
+}
+
+// lookup finds the match for the given 4-byte or 16-byte IP.
+func (idx *CIDRIndex[S]) lookup(ip []byte) match[S] {
 	current := 0
 	bestID := S(-1)
-	bestMaskLen := int8(-1)
+	bestMaskLen := -1
 
 	// Traverse up to 128 bits for IPv6 (or 32 for IPv4).
 	maxBits := 128
@@ -108,114 +251,70 @@ func (idx *CIDRIndex[S]) LookupIP(ip net.IP) string {
 		maxBits = 32 // IPv4.
 	}
 
+	bits := maxBits
+
 	for i := 0; i < maxBits; i++ {
 		// Check if current node has an id and update best match if mask is longer.
-		if idx.nodes[current].id != -1 && idx.nodes[current].maskLen > bestMaskLen {
+		if idx.nodes[current].id != -1 && idx.nodes[current].bits() > bestMaskLen {
 			bestID = idx.nodes[current].id
-			bestMaskLen = idx.nodes[current].maskLen
+			bestMaskLen = idx.nodes[current].bits()
 		}
 
 		// Get the next bit.
 		bit := (ip[i/8] >> (7 - (i % 8))) & 1
 		childIndex := idx.nodes[current].children[bit]
 		if childIndex == -1 {
+			bits = i + 1
+
 			break // No further path.
 		}
 		current = int(childIndex)
 	}
 
 	// Check the final node for a better match.
-	if idx.nodes[current].id != -1 && idx.nodes[current].maskLen > bestMaskLen {
+	if idx.nodes[current].id != -1 && idx.nodes[current].bits() > bestMaskLen {
 		bestID = idx.nodes[current].id
+		bestMaskLen = idx.nodes[current].bits()
 	}
 
-	if bestID == -1 {
+	return match[S]{id: bestID, maskLen: bestMaskLen, bits: bits}
+}
+
+// name returns the name for id, or "" if id is -1.
+func (idx *CIDRIndex[S]) name(id S) string {
+	if id == -1 {
 		return ""
 	}
 
-	return idx.names[bestID-1]
+	return idx.names[id-1]
 }
 
-// Minimize merges isomorphic subtrees, producing a minimal DAWG.
-// Safe to call only once after all insertions are done.
-// Reduces node count typically by 60–80% on real-world CIDR sets.
-func (idx *CIDRIndex[S]) Minimize() {
-	if len(idx.nodes) <= 1 {
-		return
+// LookupPrefix finds the name and the prefix of the CIDR that contains the given address.
+// Returns "" and an invalid prefix if no matching CIDR is found.
+func (idx *CIDRIndex[S]) LookupPrefix(addr netip.Addr) (string, netip.Prefix, error) {
+	m := idx.lookupAddrID(addr)
+	if m.id == -1 {
+		return "", netip.Prefix{}, nil
 	}
 
-	// signature uniquely identifies a node after children are canonicalized
-	type sig struct {
-		ch0, ch1 int32 // canonical child indices (-1 if none)
-		id       S
-		maskLen  int8
-	}
-
-	// Maps signature → canonical node index in the final array
-	sigToIndex := make(map[sig]int32)
-
-	// old index → new canonical index
-	remap := make([]int32, len(idx.nodes))
-
-	// We'll build the new minimal node list here
-	var minimal []trieNode[S]
-
-	// Process nodes in reverse order (bottom-up)
-	for i := len(idx.nodes) - 1; i >= 0; i-- {
-		old := idx.nodes[i]
-
-		// Resolve children to their future canonical indices
-		ch0 := int32(-1)
-		if old.children[0] != -1 {
-			ch0 = remap[old.children[0]]
-		}
-		ch1 := int32(-1)
-		if old.children[1] != -1 {
-			ch1 = remap[old.children[1]]
-		}
-
-		currentSig := sig{
-			ch0:     ch0,
-			ch1:     ch1,
-			id:      old.id,
-			maskLen: old.maskLen,
-		}
+	return idx.name(m.id), matchedPrefix(addr, m.maskLen), nil
+}
 
-		// Reuse existing node if signature already exists
-		if newIdx, exists := sigToIndex[currentSig]; exists {
-			remap[i] = newIdx
-		} else {
-			// First time we see this signature → assign new canonical index
-			newIdx := int32(len(minimal))
-			remap[i] = newIdx
-			sigToIndex[currentSig] = newIdx
-
-			// Append node with already-remapped children
-			minimal = append(minimal, trieNode[S]{
-				children: [2]int32{ch0, ch1},
-				id:       old.id,
-				maskLen:  old.maskLen,
-			})
-		}
+// LookupUniform finds the name for the given address and a prefix that contains it,
+// all addresses within the prefix resolve to the same name.
+// Returns an invalid prefix for an invalid address.
+func (idx *CIDRIndex[S]) LookupUniform(addr netip.Addr) (string, netip.Prefix, error) {
+	if !addr.IsValid() {
+		return "", netip.Prefix{}, nil
 	}
 
-	// Root is always at 0 → remap it
-	// (after minimization it might move, but we keep it at index 0)
-	finalRoot := remap[0]
-	if finalRoot != 0 {
-		// Swap root node to position 0
-		minimal[finalRoot], minimal[0] = minimal[0], minimal[finalRoot]
-
-		// Fix up all references to the swapped nodes
-		for i := range remap {
-			switch remap[i] {
-			case 0:
-				remap[i] = finalRoot
-			case finalRoot:
-				remap[i] = 0
-			}
-		}
-	}
+	m := idx.lookupAddrID(addr)
+
+	return idx.name(m.id), matchedPrefix(addr, m.bits), nil
+}
 
-	idx.nodes = minimal
+// LookupBatch finds names for all ips and stores them in out, out must be at least as long as ips.
+// Addresses are deduplicated and visited in sorted order.
+func (idx *CIDRIndex[S]) LookupBatch(ips []netip.Addr, out []string) {
+	lookupBatch(ips, out, idx.lookupAddr)
 }
@@ -1,8 +1,11 @@
 package netrie_test
 
 import (
+	"net/netip"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/vearutop/netrie"
 )
 
@@ -44,3 +47,28 @@ func TestNewCIDRTrie(t *testing.T) {
 		}
 	}
 }
+
+func TestCIDRIndex_LookupPrefix(t *testing.T) {
+	trie := netrie.NewCIDRIndex()
+	require.NoError(t, trie.AddCIDR("192.168.0.0/16", "net1"))
+	require.NoError(t, trie.AddCIDR("192.168.1.0/24", "net2"))
+	require.NoError(t, trie.AddCIDR("2001:db8::/32", "net3"))
+	require.NoError(t, trie.AddCIDR("2001:db8::1/128", "host"))
+
+	for _, tc := range []struct {
+		ip     string
+		name   string
+		prefix string
+	}{
+		{"192.168.1.100", "net2", "192.168.1.0/24"},
+		{"::ffff:192.168.2.100", "net1", "192.168.0.0/16"},
+		{"2001:db8::2", "net3", "2001:db8::/32"},
+		{"2001:db8::1", "host", "2001:db8::1/128"},
+		{"10.0.0.1", "", "invalid Prefix"},
+	} {
+		name, prefix, err := trie.LookupPrefix(netip.MustParseAddr(tc.ip))
+		require.NoError(t, err)
+		assert.Equal(t, tc.name, name, tc.ip)
+		assert.Equal(t, tc.prefix, prefix.String(), tc.ip)
+	}
+}
@@ -0,0 +1,221 @@
+// Package netrietest provides a reference CIDR matcher and random generators to test netrie indexes.
+package netrietest
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand/v2"
+	"net/netip"
+)
+
+// Prefix is a CIDR with an associated name.
+type Prefix struct {
+	Prefix netip.Prefix
+	Name   string
+}
+
+// Reference is a trivially correct CIDR matcher that scans all prefixes on every lookup.
+// Only prefixes of the address family of an address are matched.
+type Reference struct {
+	prefixes []Prefix
+
+	// SharedRoot enables matching of prefixes of both address families, same as in netrie.CIDRIndex.
+	// Index matches prefixes and addresses as bit strings of 32 bits for IPv4 and 128 bits for IPv6
+	// in a single trie, so IPv4 prefix also matches IPv6 addresses with the same leading bits
+	// and short IPv6 prefix matches IPv4 addresses.
+	SharedRoot bool
+}
+
+// Add adds a prefix with a name, name of a prefix that was added before is replaced.
+func (r *Reference) Add(p netip.Prefix, name string) {
+	p = netip.PrefixFrom(p.Addr().Unmap(), p.Bits()).Masked()
+
+	for i, e := range r.prefixes {
+		if e.Prefix == p {
+			r.prefixes[i].Name = name
+
+			return
+		}
+	}
+
+	r.prefixes = append(r.prefixes, Prefix{Prefix: p, Name: name})
+}
+
+// Len returns the number of unique prefixes.
+func (r *Reference) Len() int {
+	return len(r.prefixes)
+}
+
+// Prefixes returns unique prefixes in order of insertion.
+func (r *Reference) Prefixes() []Prefix {
+	return r.prefixes
+}
+
+// Lookup returns the name of the longest prefix that contains the address and the matched prefix
+// in the address family of the address, or "" and an invalid prefix if no prefix matches.
+func (r *Reference) Lookup(addr netip.Addr) (string, netip.Prefix) {
+	if !addr.IsValid() {
+		return "", netip.Prefix{}
+	}
+
+	addr = addr.Unmap()
+	ip := addr.AsSlice()
+
+	best := -1
+	name := ""
+
+	for _, p := range r.prefixes {
+		if !r.SharedRoot && p.Prefix.Addr().Is4() != addr.Is4() {
+			continue
+		}
+
+		bits := p.Prefix.Bits()
+		if bits <= best || bits > 8*len(ip) || !hasPrefix(ip, p.Prefix.Addr().AsSlice(), bits) {
+			continue
+		}
+
+		best = bits
+		name = p.Name
+	}
+
+	if best == -1 {
+		return "", netip.Prefix{}
+	}
+
+	matched, _ := addr.Prefix(best)
+
+	return name, matched
+}
+
+// hasPrefix checks if the first bits of ip and prefix are equal.
+func hasPrefix(ip, prefix []byte, bits int) bool {
+	n := bits / 8
+	if !bytes.Equal(ip[:n], prefix[:n]) {
+		return false
+	}
+
+	if bits%8 == 0 {
+		return true
+	}
+
+	mask := byte(0xff) << (8 - bits%8)
+
+	return ip[n]&mask == prefix[n]&mask
+}
+
+// Generator produces random prefixes and addresses, results are reproducible for the same seed.
+type Generator struct {
+	rnd *rand.Rand
+
+	// Names is a number of distinct names assigned to prefixes, default 16.
+	// Few names produce many equal subtrees.
+	Names int
+
+	// Nested is a probability of a prefix to be a more specific prefix of a previous one, default 0.3.
+	Nested float64
+}
+
+// NewGenerator creates a Generator with a seed.
+func NewGenerator(seed uint64) *Generator {
+	return &Generator{
+		rnd:    rand.New(rand.NewPCG(seed, seed^0x9e3779b97f4a7c15)),
+		Names:  16,
+		Nested: 0.3,
+	}
+}
+
+// IPv4Prefixes returns n random IPv4 prefixes from /8 to /32.
+func (g *Generator) IPv4Prefixes(n int) []Prefix {
+	return g.prefixes(n, 4, 8)
+}
+
+// IPv6Prefixes returns n random IPv6 prefixes from /16 to /128.
+func (g *Generator) IPv6Prefixes(n int) []Prefix {
+	return g.prefixes(n, 16, 16)
+}
+
+func (g *Generator) prefixes(n int, size int, minBits int) []Prefix {
+	res := make([]Prefix, 0, n)
+
+	for len(res) < n {
+		ip := g.randomAddr(size).AsSlice()
+		bits := minBits + g.rnd.IntN(8*size-minBits+1)
+
+		if len(res) > 0 && g.rnd.Float64() < g.Nested {
+			// More specific prefix of a previous one.
+			parent := res[g.rnd.IntN(len(res))].Prefix
+			if parent.Bits() == 8*size {
+				continue
+			}
+
+			copyBits(ip, parent.Addr().AsSlice(), parent.Bits())
+			bits = parent.Bits() + 1 + g.rnd.IntN(8*size-parent.Bits())
+		}
+
+		addr, _ := netip.AddrFromSlice(ip)
+		p, _ := addr.Prefix(bits)
+
+		res = append(res, Prefix{Prefix: p, Name: g.name()})
+	}
+
+	return res
+}
+
+func (g *Generator) name() string {
+	return fmt.Sprintf("name-%d", g.rnd.IntN(max(g.Names, 1)))
+}
+
+// copyBits copies the first bits of src to dst.
+func copyBits(dst, src []byte, bits int) {
+	for i := 0; i < bits; i++ {
+		mask := byte(1) << (7 - (i % 8))
+		dst[i/8] = dst[i/8]&^mask | src[i/8]&mask
+	}
+}
+
+// Addrs returns n random addresses: addresses within random prefixes, first and last addresses of prefixes,
+// and random addresses of the same families.
+func (g *Generator) Addrs(prefixes []Prefix, n int) []netip.Addr {
+	res := make([]netip.Addr, 0, n)
+
+	for len(res) < n {
+		if len(prefixes) == 0 {
+			res = append(res, g.randomAddr(4))
+
+			continue
+		}
+
+		p := prefixes[g.rnd.IntN(len(prefixes))].Prefix
+		ip := p.Addr().AsSlice()
+
+		switch g.rnd.IntN(4) {
+		case 0: // First address.
+		case 1: // Last address.
+			for i := p.Bits(); i < 8*len(ip); i++ {
+				ip[i/8] |= 1 << (7 - (i % 8))
+			}
+		case 2: // Random address of the same family.
+			ip = g.randomAddr(len(ip)).AsSlice()
+		default: // Random address within prefix.
+			r := g.randomAddr(len(ip)).AsSlice()
+			copyBits(r, ip, p.Bits())
+			ip = r
+		}
+
+		addr, _ := netip.AddrFromSlice(ip)
+		res = append(res, addr)
+	}
+
+	return res
+}
+
+func (g *Generator) randomAddr(size int) netip.Addr {
+	ip := make([]byte, size)
+	for i := range ip {
+		ip[i] = byte(g.rnd.Uint32())
+	}
+
+	addr, _ := netip.AddrFromSlice(ip)
+
+	return addr
+}
@@ -0,0 +1,103 @@
+package netrietest_test
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vearutop/netrie/netrietest"
+)
+
+func TestReference_Lookup(t *testing.T) {
+	r := netrietest.Reference{}
+	r.Add(netip.MustParsePrefix("10.0.0.0/8"), "a")
+	r.Add(netip.MustParsePrefix("10.1.0.0/16"), "b")
+	r.Add(netip.MustParsePrefix("10.1.2.3/32"), "c")
+	r.Add(netip.MustParsePrefix("2001:db8::/32"), "d")
+	r.Add(netip.MustParsePrefix("2001:db8::1/128"), "e")
+	r.Add(netip.MustParsePrefix("10.1.0.0/16"), "f")
+
+	assert.Equal(t, 5, r.Len())
+
+	for _, tc := range []struct {
+		addr, name, prefix string
+	}{
+		{"10.2.3.4", "a", "10.0.0.0/8"},
+		{"10.1.3.4", "f", "10.1.0.0/16"},
+		{"10.1.2.3", "c", "10.1.2.3/32"},
+		{"::ffff:10.1.2.3", "c", "10.1.2.3/32"},
+		{"11.0.0.1", "", ""},
+		{"2001:db8::2", "d", "2001:db8::/32"},
+		{"2001:db8::1", "e", "2001:db8::1/128"},
+		{"a01:300::", "", ""},
+		{"32.1.13.184", "", ""},
+	} {
+		name, prefix := r.Lookup(netip.MustParseAddr(tc.addr))
+		assert.Equal(t, tc.name, name, tc.addr)
+
+		if tc.prefix == "" {
+			assert.False(t, prefix.IsValid())
+		} else {
+			assert.Equal(t, tc.prefix, prefix.String(), tc.addr)
+		}
+	}
+}
+
+func TestReference_Lookup_sharedRoot(t *testing.T) {
+	r := netrietest.Reference{SharedRoot: true}
+	r.Add(netip.MustParsePrefix("10.1.0.0/16"), "a")
+	r.Add(netip.MustParsePrefix("2001:db8::/32"), "b")
+
+	// IPv4 prefix matches leading bits of IPv6 address.
+	name, prefix := r.Lookup(netip.MustParseAddr("a01:300::"))
+	assert.Equal(t, "a", name)
+	assert.Equal(t, "a01::/16", prefix.String())
+
+	// IPv6 prefix of up to 32 bits matches IPv4 address.
+	name, prefix = r.Lookup(netip.MustParseAddr("32.1.13.184"))
+	assert.Equal(t, "b", name)
+	assert.Equal(t, "32.1.13.184/32", prefix.String())
+
+	name, _ = r.Lookup(netip.MustParseAddr("10.2.0.1"))
+	assert.Equal(t, "", name)
+}
+
+func TestGenerator(t *testing.T) {
+	g := netrietest.NewGenerator(1)
+	v4 := g.IPv4Prefixes(100)
+	v6 := g.IPv6Prefixes(100)
+
+	require.Len(t, v4, 100)
+	require.Len(t, v6, 100)
+
+	for _, p := range v4 {
+		assert.True(t, p.Prefix.Addr().Is4())
+		assert.Equal(t, p.Prefix, p.Prefix.Masked())
+		assert.GreaterOrEqual(t, p.Prefix.Bits(), 8)
+	}
+
+	for _, p := range v6 {
+		assert.True(t, p.Prefix.Addr().Is6())
+		assert.GreaterOrEqual(t, p.Prefix.Bits(), 16)
+	}
+
+	// Same seed produces same prefixes.
+	assert.Equal(t, v4, netrietest.NewGenerator(1).IPv4Prefixes(100))
+
+	r := netrietest.Reference{}
+	for _, p := range v4 {
+		r.Add(p.Prefix, p.Name)
+	}
+
+	// Most of addresses are within prefixes.
+	hits := 0
+
+	for _, addr := range g.Addrs(v4, 1000) {
+		if name, _ := r.Lookup(addr); name != "" {
+			hits++
+		}
+	}
+
+	assert.Greater(t, hits, 500)
+}
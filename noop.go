@@ -2,6 +2,7 @@ package netrie
 
 import (
 	"net"
+	"net/netip"
 )
 
 // Noop is a placeholder type that implements various methods with empty or no-op behavior.
@@ -21,6 +22,11 @@ func (n Noop) SafeLookupIP(ip net.IP) (string, error) { return "", nil }
 // LookupIP is a no-op method that accepts an IP and always returns an empty string.
 func (n Noop) LookupIP(ip net.IP) string { return "" }
 
+// LookupBatch is a no-op method that fills out with empty strings.
+func (n Noop) LookupBatch(ips []netip.Addr, out []string) {
+	clear(out[:len(ips)])
+}
+
 // Lookup is a no-op method that takes an IP address as a string and always returns an empty string.
 func (n Noop) Lookup(ipStr string) string { return "" }
 
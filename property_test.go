@@ -0,0 +1,249 @@
+package netrie_test
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vearutop/netrie"
+	"github.com/vearutop/netrie/netrietest"
+)
+
+// propertyCase is a random prefix set with a reference matcher and addresses to look up.
+type propertyCase struct {
+	prefixes []netrietest.Prefix
+	ref      *netrietest.Reference
+	addrs    []netip.Addr
+
+	// Expected names and matched prefixes of addrs.
+	names   []string
+	matched []netip.Prefix
+
+	// uniform caches reference names of first and last addresses of uniform prefixes.
+	uniform map[netip.Prefix][2]string
+}
+
+func propertyCases(t *testing.T) map[string]propertyCase {
+	t.Helper()
+
+	cases := map[string]propertyCase{}
+
+	for seed := uint64(1); seed <= 3; seed++ {
+		g := netrietest.NewGenerator(seed)
+
+		for family, prefixes := range map[string][]netrietest.Prefix{
+			"ipv4":  g.IPv4Prefixes(1000),
+			"ipv6":  g.IPv6Prefixes(1000),
+			"mixed": append(g.IPv4Prefixes(500), g.IPv6Prefixes(500)...),
+		} {
+			// Index shares one trie root for IPv4 and IPv6, so in mixed sets an address can match
+			// a prefix of the other family with the same leading bits, see TestCIDRIndex_property_family.
+			ref := &netrietest.Reference{SharedRoot: family == "mixed"}
+			for _, p := range prefixes {
+				ref.Add(p.Prefix, p.Name)
+			}
+
+			pc := propertyCase{
+				prefixes: prefixes,
+				ref:      ref,
+				addrs:    g.Addrs(prefixes, 2000),
+				uniform:  map[netip.Prefix][2]string{},
+			}
+
+			for _, addr := range pc.addrs {
+				name, prefix := ref.Lookup(addr)
+				pc.names = append(pc.names, name)
+				pc.matched = append(pc.matched, prefix)
+			}
+
+			cases[fmt.Sprintf("%s_%d", family, seed)] = pc
+		}
+	}
+
+	return cases
+}
+
+func (pc propertyCase) index(t *testing.T) *netrie.CIDRIndex[int16] {
+	t.Helper()
+
+	idx := netrie.NewCIDRIndex()
+
+	for _, p := range pc.prefixes {
+		require.NoError(t, idx.AddCIDR(p.Prefix.String(), p.Name))
+	}
+
+	return idx
+}
+
+// assertReference compares lookups of the index with the reference.
+func (pc propertyCase) assertReference(t *testing.T, l netrie.IPLookuper, exactPrefix bool) {
+	t.Helper()
+
+	names := make([]string, len(pc.addrs))
+	netrie.LookupBatch(l, pc.addrs, names)
+
+	for i, addr := range pc.addrs {
+		name, prefix := pc.names[i], pc.matched[i]
+
+		require.Equal(t, name, l.LookupIP(net.IP(addr.AsSlice())), addr)
+		require.Equal(t, name, names[i], addr)
+
+		if pl, ok := l.(netrie.PrefixLookuper); ok && exactPrefix {
+			n, p, err := pl.LookupPrefix(addr)
+			require.NoError(t, err)
+			require.Equal(t, name, n, addr)
+			require.Equal(t, prefix, p, addr)
+		}
+
+		// All addresses of uniform prefix have the same name, first and last are checked.
+		if ul, ok := l.(netrie.UniformLookuper); ok {
+			n, p, err := ul.LookupUniform(addr)
+			require.NoError(t, err)
+			require.Equal(t, name, n, addr)
+			require.True(t, p.Contains(addr.Unmap()), "%s in %s", addr, p)
+
+			edges, ok := pc.uniform[p]
+			if !ok {
+				edges[0], _ = pc.ref.Lookup(p.Masked().Addr())
+				edges[1], _ = pc.ref.Lookup(lastAddr(p))
+				pc.uniform[p] = edges
+			}
+
+			require.Equal(t, name, edges[0], "%s first of %s", addr, p)
+			require.Equal(t, name, edges[1], "%s last of %s", addr, p)
+		}
+	}
+}
+
+func lastAddr(p netip.Prefix) netip.Addr {
+	ip := p.Masked().Addr().AsSlice()
+	for i := p.Bits(); i < 8*len(ip); i++ {
+		ip[i/8] |= 1 << (7 - (i % 8))
+	}
+
+	addr, _ := netip.AddrFromSlice(ip)
+
+	return addr
+}
+
+func TestCIDRIndex_property(t *testing.T) {
+	for name, pc := range propertyCases(t) {
+		t.Run(name, func(t *testing.T) {
+			idx := pc.index(t)
+			pc.assertReference(t, idx, true)
+
+			saved := bytes.NewBuffer(nil)
+			require.NoError(t, idx.Save(saved))
+
+			idx.Minimize()
+			pc.assertReference(t, idx, true)
+
+			minimized := bytes.NewBuffer(nil)
+			require.NoError(t, idx.Save(minimized))
+
+			indexed := bytes.NewBuffer(nil)
+			require.NoError(t, idx.SaveCompressed(indexed, func(o *netrie.SaveOptions) {
+				o.Compression = netrie.CompressionNone
+				o.NamesOffsets = true
+			}))
+
+			for _, data := range [][]byte{saved.Bytes(), minimized.Bytes(), indexed.Bytes()} {
+				l, err := netrie.Load(bytes.NewReader(data))
+				require.NoError(t, err)
+				pc.assertReference(t, l, true)
+
+				f, err := netrie.Open(bytes.NewReader(data), func(o *netrie.Options) {
+					o.NameCache = 8
+				})
+				require.NoError(t, err)
+				pc.assertReference(t, f, true)
+
+				require.NoError(t, netrie.Validate(bytes.NewReader(data)))
+			}
+
+			compressed := bytes.NewBuffer(nil)
+			require.NoError(t, idx.SaveCompressed(compressed, func(o *netrie.SaveOptions) {
+				o.BlockSize = 4096
+				o.FrontCodedNames = true
+			}))
+
+			f, err := netrie.Open(bytes.NewReader(compressed.Bytes()))
+			require.NoError(t, err)
+			pc.assertReference(t, f, true)
+		})
+	}
+}
+
+func TestBuilder_property(t *testing.T) {
+	for name, pc := range propertyCases(t) {
+		t.Run(name, func(t *testing.T) {
+			// Aggregation keeps names, but matched prefixes may become shorter.
+			idx := pc.index(t)
+			idx.Aggregate()
+			pc.assertReference(t, idx, false)
+
+			b := netrie.NewBuilder()
+			for _, p := range pc.prefixes {
+				require.NoError(t, b.AddCIDR(p.Prefix.String(), p.Name))
+			}
+
+			fi := b.Freeze()
+			require.Equal(t, pc.ref.Len(), fi.Len())
+			pc.assertReference(t, fi, true)
+
+			sorted := slices.Clone(pc.ref.Prefixes())
+			slices.SortStableFunc(sorted, func(a, b netrietest.Prefix) int {
+				return netrie.ComparePrefixes(a.Prefix, b.Prefix)
+			})
+
+			sb := netrie.NewSortedBuilder()
+			for _, p := range sorted {
+				require.NoError(t, sb.AddPrefix(p.Prefix, p.Name))
+			}
+
+			pc.assertReference(t, sb.Finish(), true)
+		})
+	}
+}
+
+// TestCIDRIndex_property_family documents the known difference of the index from strict family matching:
+// an address only matches a prefix of the other family with the same leading bits if it is more specific.
+func TestCIDRIndex_property_family(t *testing.T) {
+	for seed := uint64(1); seed <= 3; seed++ {
+		g := netrietest.NewGenerator(seed)
+		v4, v6 := g.IPv4Prefixes(500), g.IPv6Prefixes(500)
+		prefixes := append(slices.Clone(v4), v6...)
+
+		strict := &netrietest.Reference{}
+		cross := map[bool]*netrietest.Reference{
+			true:  {SharedRoot: true}, // IPv6 prefixes for IPv4 addresses.
+			false: {SharedRoot: true}, // IPv4 prefixes for IPv6 addresses.
+		}
+		idx := netrie.NewCIDRIndex()
+
+		for _, p := range prefixes {
+			strict.Add(p.Prefix, p.Name)
+			cross[p.Prefix.Addr().Is6()].Add(p.Prefix, p.Name)
+			require.NoError(t, idx.AddCIDR(p.Prefix.String(), p.Name))
+		}
+
+		for _, addr := range g.Addrs(prefixes, 2000) {
+			got, gotPrefix, err := idx.LookupPrefix(addr)
+			require.NoError(t, err)
+
+			name, prefix := strict.Lookup(addr)
+			if got == name && gotPrefix == prefix {
+				continue
+			}
+
+			crossName, crossPrefix := cross[addr.Is4()].Lookup(addr)
+			require.Equal(t, crossName, got, addr)
+			require.Equal(t, crossPrefix, gotPrefix, addr)
+			require.Greater(t, crossPrefix.Bits(), prefix.Bits(), addr)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package netrie
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+)
+
+// RangeNets decomposes an inclusive range of addresses into a minimal list of CIDRs.
+// Returns an error if start and end are of different families or start is greater than end.
+func RangeNets(start, end netip.Addr) ([]*net.IPNet, error) {
+	start, end = start.Unmap(), end.Unmap()
+
+	if !start.IsValid() || !end.IsValid() || start.BitLen() != end.BitLen() {
+		return nil, fmt.Errorf("invalid range %s-%s", start, end)
+	}
+
+	if end.Less(start) {
+		return nil, fmt.Errorf("invalid range %s-%s: start is greater than end", start, end)
+	}
+
+	var nets []*net.IPNet
+
+	for {
+		bits := start.BitLen()
+
+		// Find the widest prefix that begins at start and ends not after end.
+		for b := 0; b < bits; b++ {
+			p := netip.PrefixFrom(start, b)
+			if p.Masked().Addr() == start && !end.Less(lastAddr(p)) {
+				bits = b
+
+				break
+			}
+		}
+
+		p := netip.PrefixFrom(start, bits)
+		nets = append(nets, &net.IPNet{
+			IP:   start.AsSlice(),
+			Mask: net.CIDRMask(bits, start.BitLen()),
+		})
+
+		last := lastAddr(p)
+		if last == end {
+			break
+		}
+
+		start = last.Next()
+	}
+
+	return nets, nil
+}
+
+// lastAddr returns the last address of the prefix.
+func lastAddr(p netip.Prefix) netip.Addr {
+	b := p.Addr().AsSlice()
+
+	for i := p.Bits(); i < len(b)*8; i++ {
+		b[i/8] |= 1 << (7 - i%8)
+	}
+
+	a, _ := netip.AddrFromSlice(b)
+
+	return a
+}
+
+// AddRange adds an inclusive range of addresses decomposed into a minimal set of CIDRs with an associated name.
+// Returns error if range is invalid or ErrTooManyNames if the name does not fit in the namespace.
+func (idx *CIDRIndex[S]) AddRange(start, end netip.Addr, name string) error {
+	nets, err := RangeNets(start, end)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	for _, n := range nets {
+		if err := idx.add(n, name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
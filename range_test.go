@@ -0,0 +1,59 @@
+package netrie_test
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vearutop/netrie"
+)
+
+func TestRangeNets(t *testing.T) {
+	for _, tc := range []struct {
+		start, end string
+		expected   []string
+	}{
+		{"10.0.0.0", "10.0.0.255", []string{"10.0.0.0/24"}},
+		{"10.0.0.1", "10.0.0.6", []string{"10.0.0.1/32", "10.0.0.2/31", "10.0.0.4/31", "10.0.0.6/32"}},
+		{"10.0.0.1", "10.0.0.1", []string{"10.0.0.1/32"}},
+		{"0.0.0.0", "255.255.255.255", []string{"0.0.0.0/0"}},
+		{"255.255.255.254", "255.255.255.255", []string{"255.255.255.254/31"}},
+		{"::ffff:1.2.3.4", "1.2.3.4", []string{"1.2.3.4/32"}},
+		{"2001:db8::", "2001:db8::1:0", []string{"2001:db8::/112", "2001:db8::1:0/128"}},
+		{"::", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", []string{"::/0"}},
+	} {
+		nets, err := netrie.RangeNets(netip.MustParseAddr(tc.start), netip.MustParseAddr(tc.end))
+		require.NoError(t, err)
+
+		got := make([]string, 0, len(nets))
+		for _, n := range nets {
+			got = append(got, n.String())
+		}
+
+		assert.Equal(t, tc.expected, got, tc.start+"-"+tc.end)
+	}
+
+	_, err := netrie.RangeNets(netip.MustParseAddr("10.0.0.2"), netip.MustParseAddr("10.0.0.1"))
+	require.EqualError(t, err, "invalid range 10.0.0.2-10.0.0.1: start is greater than end")
+
+	_, err = netrie.RangeNets(netip.MustParseAddr("10.0.0.2"), netip.MustParseAddr("2001:db8::"))
+	require.EqualError(t, err, "invalid range 10.0.0.2-2001:db8::")
+}
+
+func TestCIDRIndex_AddRange(t *testing.T) {
+	tr := netrie.NewCIDRIndex()
+
+	require.NoError(t, tr.AddRange(netip.MustParseAddr("192.168.0.10"), netip.MustParseAddr("192.168.1.20"), "net1"))
+	require.NoError(t, tr.AddRange(netip.MustParseAddr("2001:db8::"), netip.MustParseAddr("2001:db8::ff"), "net2"))
+	require.Error(t, tr.AddRange(netip.MustParseAddr("192.168.1.20"), netip.MustParseAddr("192.168.0.10"), "net3"))
+
+	assert.Equal(t, 10, tr.Len())
+	assert.Equal(t, "", tr.Lookup("192.168.0.9"))
+	assert.Equal(t, "net1", tr.Lookup("192.168.0.10"))
+	assert.Equal(t, "net1", tr.Lookup("192.168.0.255"))
+	assert.Equal(t, "net1", tr.Lookup("192.168.1.20"))
+	assert.Equal(t, "", tr.Lookup("192.168.1.21"))
+	assert.Equal(t, "net2", tr.Lookup("2001:db8::ff"))
+	assert.Equal(t, "", tr.Lookup("2001:db8::100"))
+}
@@ -0,0 +1,291 @@
+package remote
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/vearutop/netrie"
+)
+
+// ClientOptions configures Client.
+type ClientOptions struct {
+	MaxFrameSize int           // Default DefaultMaxFrameSize.
+	DialTimeout  time.Duration // Default 5s.
+
+	// RequestTimeout limits time to send a request and receive a response, default 5s, negative disables it.
+	// Connection is closed on timeout and reestablished on the next request.
+	RequestTimeout time.Duration
+}
+
+// Client is a remote netrie.IPLookuper, it uses a single connection and is safe for concurrent use.
+// Broken connection is reestablished on the next request.
+type Client struct {
+	opts    ClientOptions
+	index   uint16
+	network string
+	address string
+
+	mu     sync.Mutex
+	closed bool
+	conn   net.Conn
+	r      *bufio.Reader
+	w      *bufio.Writer
+	buf    []byte
+
+	info info
+}
+
+var (
+	_ netrie.IPLookuper      = &Client{}
+	_ netrie.BatchIPLookuper = &Client{}
+)
+
+// Dial connects to a Server and fetches information about the index.
+// Network is "tcp" or "unix".
+func Dial(network, address string, index uint16, opts ...func(o *ClientOptions)) (*Client, error) {
+	c := &Client{index: index, network: network, address: address}
+
+	c.opts.MaxFrameSize = DefaultMaxFrameSize
+	c.opts.DialTimeout = 5 * time.Second
+	c.opts.RequestTimeout = 5 * time.Second
+
+	for _, opt := range opts {
+		opt(&c.opts)
+	}
+
+	if err := c.dial(); err != nil {
+		return nil, err
+	}
+
+	err := c.roundTrip(context.Background(), func(b []byte) []byte {
+		b = append(b, msgInfo)
+
+		return binary.BigEndian.AppendUint16(b, index)
+	}, func(resp []byte) error {
+		body, err := parseStatus(resp)
+		if err != nil {
+			return err
+		}
+
+		return json.Unmarshal(body, &c.info)
+	})
+	if err != nil {
+		_ = c.Close()
+
+		return nil, fmt.Errorf("get index info: %w", err)
+	}
+
+	if c.info.Metadata == nil {
+		c.info.Metadata = &netrie.Metadata{}
+	}
+
+	return c, nil
+}
+
+func (c *Client) dial() error {
+	conn, err := net.DialTimeout(c.network, c.address, c.opts.DialTimeout)
+	if err != nil {
+		return err
+	}
+
+	c.conn = conn
+	c.r = bufio.NewReader(conn)
+	c.w = bufio.NewWriter(conn)
+
+	return nil
+}
+
+// roundTrip sends a request built by makeReq and passes the response to parse, buffer is reused between calls.
+// Request is limited by RequestTimeout and by deadline and cancellation of ctx.
+func (c *Client) roundTrip(ctx context.Context, makeReq func(b []byte) []byte, parse func(resp []byte) error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return net.ErrClosed
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if c.conn == nil {
+		if err := c.dial(); err != nil {
+			return err
+		}
+	}
+
+	var (
+		deadline time.Time
+		ctxDL    bool
+	)
+
+	if c.opts.RequestTimeout > 0 {
+		deadline = time.Now().Add(c.opts.RequestTimeout)
+	}
+
+	if d, ok := ctx.Deadline(); ok && (deadline.IsZero() || d.Before(deadline)) {
+		deadline, ctxDL = d, true
+	}
+
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		c.reset()
+
+		return err
+	}
+
+	// Cancellation interrupts pending reads and writes.
+	conn := c.conn
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	c.buf = makeReq(c.buf[:0])
+
+	if err := writeFrame(c.w, c.buf); err != nil {
+		c.reset()
+
+		return ctxErr(ctx, ctxDL, err)
+	}
+
+	resp, err := readFrame(c.r, c.opts.MaxFrameSize, c.buf)
+	if err != nil {
+		c.reset()
+
+		return ctxErr(ctx, ctxDL, err)
+	}
+
+	c.buf = resp
+
+	return parse(resp)
+}
+
+// ctxErr adds the error of ctx to err if ctx is done or its deadline is exceeded by connection deadline.
+func ctxErr(ctx context.Context, ctxDeadline bool, err error) error {
+	if ctx.Err() != nil {
+		return fmt.Errorf("%w: %w", ctx.Err(), err)
+	}
+
+	if ctxDeadline && errors.Is(err, os.ErrDeadlineExceeded) {
+		return fmt.Errorf("%w: %w", context.DeadlineExceeded, err)
+	}
+
+	return err
+}
+
+// LookupBatchErr finds names for all ips and stores them in out, out must be at least as long as ips.
+func (c *Client) LookupBatchErr(ips []netip.Addr, out []string) error {
+	return c.LookupBatchContext(context.Background(), ips, out)
+}
+
+// LookupBatchContext finds names for all ips and stores them in out, out must be at least as long as ips.
+// Request is stopped when ctx is done.
+func (c *Client) LookupBatchContext(ctx context.Context, ips []netip.Addr, out []string) error {
+	return c.roundTrip(ctx, func(b []byte) []byte {
+		return appendLookupRequest(b, c.index, ips)
+	}, func(resp []byte) error {
+		return parseLookupResponse(resp, out[:len(ips)])
+	})
+}
+
+// LookupBatch finds names for all ips and stores them in out, out must be at least as long as ips.
+// Failed lookups are reported as "error: ..." names.
+func (c *Client) LookupBatch(ips []netip.Addr, out []string) {
+	if err := c.LookupBatchErr(ips, out); err != nil {
+		for i := range ips {
+			out[i] = "error: " + err.Error()
+		}
+	}
+}
+
+// SafeLookupIP finds the name of the CIDR that contains the given IP.
+func (c *Client) SafeLookupIP(ip net.IP) (string, error) {
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return "", nil
+	}
+
+	out := make([]string, 1)
+
+	if err := c.LookupBatchErr([]netip.Addr{addr}, out); err != nil {
+		return "", err
+	}
+
+	return out[0], nil
+}
+
+// LookupIP finds the name of the CIDR that contains the given IP.
+// Returns "error: ..." if remote lookup fails.
+func (c *Client) LookupIP(ip net.IP) string {
+	name, err := c.SafeLookupIP(ip)
+	if err != nil {
+		return "error: " + err.Error()
+	}
+
+	return name
+}
+
+// Lookup finds the name of the CIDR that contains the given IP string.
+// Returns "" if no matching CIDR is found or IP is invalid.
+func (c *Client) Lookup(ipStr string) string {
+	ip := net.ParseIP(ipStr)
+	if ip == nil {
+		return "" // Invalid IP address.
+	}
+
+	return c.LookupIP(ip)
+}
+
+// Len returns the number of CIDRs in the remote index.
+func (c *Client) Len() int {
+	return c.info.Len
+}
+
+// LenNames returns the number of different names in the remote index.
+func (c *Client) LenNames() int {
+	return c.info.LenNames
+}
+
+// Metadata returns metadata of the remote index received on Dial.
+func (c *Client) Metadata() *netrie.Metadata {
+	return c.info.Metadata
+}
+
+// RemoteMemoryUsage returns memory usage of the remote index received on Dial,
+// or nil if the remote index does not report it.
+func (c *Client) RemoteMemoryUsage() *netrie.MemoryUsage {
+	return c.info.Memory
+}
+
+// reset closes the connection that may be out of sync, c.mu must be held.
+func (c *Client) reset() {
+	_ = c.conn.Close()
+	c.conn = nil
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+
+	if c.conn == nil {
+		return nil
+	}
+
+	err := c.conn.Close()
+	c.conn = nil
+
+	return err
+}
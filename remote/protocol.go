@@ -0,0 +1,280 @@
+// Package remote implements a compact length-prefixed binary lookup protocol over stream connections.
+//
+// Every message is a frame of uint32 big-endian payload length followed by payload.
+//
+// Request payload:
+//   - message type (byte), 1 for lookup, 2 for info,
+//   - index id (uint16),
+//   - for lookup: number of addresses (uint32), then each address as length (byte, 0, 4 or 16) and address bytes.
+//
+// Response payload:
+//   - status (byte), 0 for success, 1 for error followed by error message,
+//   - for lookup: number of results (uint32), name id per result (uint32, 0 for no match),
+//     number of names (uint32) and names as length (uint32) and bytes, name id N refers to N-th name,
+//   - for info: JSON encoded index information.
+package remote
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+
+	"github.com/vearutop/netrie"
+)
+
+const (
+	msgLookup byte = 1
+	msgInfo   byte = 2
+
+	statusOK  byte = 0
+	statusErr byte = 1
+
+	// DefaultMaxFrameSize limits size of a single message.
+	DefaultMaxFrameSize = 64 << 20
+)
+
+var errFrameTooLarge = errors.New("frame too large")
+
+// info is a response to info request.
+type info struct {
+	Metadata *netrie.Metadata `json:"metadata,omitempty"`
+	Len      int              `json:"len"`
+	LenNames int              `json:"len_names"`
+
+	Memory *netrie.MemoryUsage `json:"memory,omitempty"`
+}
+
+func writeFrame(w *bufio.Writer, payload []byte) error {
+	var l [4]byte
+
+	binary.BigEndian.PutUint32(l[:], uint32(len(payload)))
+
+	if _, err := w.Write(l[:]); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(payload); err != nil {
+		return err
+	}
+
+	return w.Flush()
+}
+
+// readFrame reads a frame into buf, growing it if necessary.
+func readFrame(r io.Reader, maxSize int, buf []byte) ([]byte, error) {
+	var l [4]byte
+
+	if _, err := io.ReadFull(r, l[:]); err != nil {
+		return nil, err
+	}
+
+	size := int(binary.BigEndian.Uint32(l[:]))
+	if size > maxSize {
+		return nil, fmt.Errorf("%w: %d bytes, max %d", errFrameTooLarge, size, maxSize)
+	}
+
+	if cap(buf) < size {
+		buf = make([]byte, size)
+	}
+
+	buf = buf[:size]
+
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, fmt.Errorf("read frame: %w", err)
+	}
+
+	return buf, nil
+}
+
+func appendLookupRequest(b []byte, index uint16, ips []netip.Addr) []byte {
+	b = append(b, msgLookup)
+	b = binary.BigEndian.AppendUint16(b, index)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(ips)))
+
+	for _, ip := range ips {
+		if !ip.IsValid() {
+			b = append(b, 0)
+
+			continue
+		}
+
+		ip = ip.Unmap()
+		s := ip.AsSlice()
+		b = append(b, byte(len(s)))
+		b = append(b, s...)
+	}
+
+	return b
+}
+
+func parseLookupRequest(b []byte, ips []netip.Addr) ([]netip.Addr, error) {
+	if len(b) < 4 {
+		return nil, errors.New("short lookup request")
+	}
+
+	n := int(binary.BigEndian.Uint32(b))
+	b = b[4:]
+
+	// Every address takes at least one byte.
+	if n > len(b) {
+		return nil, fmt.Errorf("invalid number of addresses: %d", n)
+	}
+
+	ips = ips[:0]
+
+	for i := 0; i < n; i++ {
+		if len(b) < 1 {
+			return nil, fmt.Errorf("short address %d", i)
+		}
+
+		l := int(b[0])
+		b = b[1:]
+
+		if l != 0 && l != 4 && l != 16 {
+			return nil, fmt.Errorf("invalid address %d length: %d", i, l)
+		}
+
+		if len(b) < l {
+			return nil, fmt.Errorf("short address %d", i)
+		}
+
+		addr, _ := netip.AddrFromSlice(b[:l])
+		ips = append(ips, addr)
+		b = b[l:]
+	}
+
+	if len(b) != 0 {
+		return nil, fmt.Errorf("unexpected %d trailing bytes", len(b))
+	}
+
+	return ips, nil
+}
+
+// appendLookupResponse encodes names as name ids and a table of unique names.
+func appendLookupResponse(b []byte, names []string) []byte {
+	b = append(b, statusOK)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(names)))
+
+	ids := make(map[string]uint32)
+
+	var table []string
+
+	for _, name := range names {
+		if name == "" {
+			b = binary.BigEndian.AppendUint32(b, 0)
+
+			continue
+		}
+
+		id, ok := ids[name]
+		if !ok {
+			table = append(table, name)
+			id = uint32(len(table))
+			ids[name] = id
+		}
+
+		b = binary.BigEndian.AppendUint32(b, id)
+	}
+
+	b = binary.BigEndian.AppendUint32(b, uint32(len(table)))
+
+	for _, name := range table {
+		b = binary.BigEndian.AppendUint32(b, uint32(len(name)))
+		b = append(b, name...)
+	}
+
+	return b
+}
+
+func parseLookupResponse(b []byte, out []string) error {
+	b, err := parseStatus(b)
+	if err != nil {
+		return err
+	}
+
+	if len(b) < 4 {
+		return errors.New("short lookup response")
+	}
+
+	n := int(binary.BigEndian.Uint32(b))
+	b = b[4:]
+
+	if n != len(out) {
+		return fmt.Errorf("unexpected number of results: %d, expected %d", n, len(out))
+	}
+
+	if len(b) < 4*n+4 {
+		return errors.New("short lookup response")
+	}
+
+	ids := b[:4*n]
+	b = b[4*n:]
+
+	tableLen := int(binary.BigEndian.Uint32(b))
+	b = b[4:]
+
+	// Every name takes at least four bytes.
+	if tableLen > len(b)/4 {
+		return fmt.Errorf("invalid number of names: %d", tableLen)
+	}
+
+	table := make([]string, tableLen)
+
+	for i := range table {
+		if len(b) < 4 {
+			return fmt.Errorf("short name %d", i)
+		}
+
+		l := int64(binary.BigEndian.Uint32(b))
+		b = b[4:]
+
+		if int64(len(b)) < l {
+			return fmt.Errorf("short name %d", i)
+		}
+
+		table[i] = string(b[:l])
+		b = b[l:]
+	}
+
+	for i := range out {
+		id := binary.BigEndian.Uint32(ids[4*i:])
+		if id == 0 {
+			out[i] = ""
+
+			continue
+		}
+
+		if int(id) > len(table) {
+			return fmt.Errorf("invalid name id %d for result %d", id, i)
+		}
+
+		out[i] = table[id-1]
+	}
+
+	return nil
+}
+
+func appendError(b []byte, err error) []byte {
+	b = append(b, statusErr)
+
+	return append(b, err.Error()...)
+}
+
+// parseStatus returns response body or error sent by server.
+func parseStatus(b []byte) ([]byte, error) {
+	if len(b) < 1 {
+		return nil, errors.New("empty response")
+	}
+
+	switch b[0] {
+	case statusOK:
+		return b[1:], nil
+	case statusErr:
+		return nil, fmt.Errorf("remote: %s", b[1:])
+	default:
+		return nil, fmt.Errorf("invalid response status: %d", b[0])
+	}
+}
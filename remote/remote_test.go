@@ -0,0 +1,212 @@
+package remote_test
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vearutop/netrie"
+	"github.com/vearutop/netrie/remote"
+)
+
+func startServer(t testing.TB, network, address string) (*remote.Server, string) {
+	t.Helper()
+
+	tr, err := netrie.LoadFromFile("../testdata/cities.bin")
+	require.NoError(t, err)
+
+	trf, err := netrie.OpenFile("../testdata/cities.bin")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = trf.Close() })
+
+	l, err := net.Listen(network, address)
+	require.NoError(t, err)
+
+	s := remote.NewServer([]netrie.IPLookuper{tr, trf})
+
+	go func() {
+		_ = s.Serve(l)
+	}()
+
+	t.Cleanup(func() { _ = s.Close() })
+
+	return s, l.Addr().String()
+}
+
+func TestClient(t *testing.T) {
+	for _, network := range []string{"tcp", "unix"} {
+		t.Run(network, func(t *testing.T) {
+			address := "127.0.0.1:0"
+			if network == "unix" {
+				address = filepath.Join(t.TempDir(), "netrie.sock")
+			}
+
+			_, address = startServer(t, network, address)
+
+			for _, index := range []uint16{0, 1} {
+				c, err := remote.Dial(network, address, index)
+				require.NoError(t, err)
+
+				require.NotNil(t, c.RemoteMemoryUsage())
+				assert.Positive(t, c.RemoteMemoryUsage().Total())
+
+				wg := sync.WaitGroup{}
+				wg.Add(20)
+
+				for i := 0; i < 20; i++ {
+					go func() {
+						defer wg.Done()
+						assert.Equal(t, 250, c.Len())
+						assert.Equal(t, 55, c.LenNames())
+						assert.Equal(t, "GB:Boxford", c.Lookup("2.125.160.217"))
+						assert.Equal(t, "GB:London", c.Lookup("81.2.69.145"))
+						assert.Equal(t, "US:San Diego", c.Lookup("2001:480:10::1"))
+						assert.Equal(t, "", c.Lookup("143.198.196.44"))
+						assert.Equal(t, "2025-08-12 17:49:01 +0000 UTC", c.Metadata().BuildDate.String())
+					}()
+				}
+
+				wg.Wait()
+
+				ips := []netip.Addr{
+					netip.MustParseAddr("81.2.69.145"),
+					{},
+					netip.MustParseAddr("::ffff:81.2.69.145"),
+					netip.MustParseAddr("2001:480:10::1"),
+				}
+				out := make([]string, len(ips))
+				netrie.LookupBatch(c, ips, out)
+				assert.Equal(t, []string{"GB:London", "", "GB:London", "US:San Diego"}, out)
+
+				require.NoError(t, c.Close())
+				assert.Contains(t, c.Lookup("81.2.69.145"), "error: ")
+			}
+
+			_, err := remote.Dial(network, address, 2)
+			require.EqualError(t, err, "get index info: remote: unknown index 2")
+		})
+	}
+}
+
+func TestClient_longName(t *testing.T) {
+	long := strings.Repeat("ü", 40000) // Longer than 0xffff bytes.
+
+	idx := netrie.NewCIDRIndex()
+	require.NoError(t, idx.AddCIDR("10.0.0.0/8", long))
+	require.NoError(t, idx.AddCIDR("10.1.0.0/16", "short"))
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	s := remote.NewServer([]netrie.IPLookuper{idx})
+
+	go func() {
+		_ = s.Serve(l)
+	}()
+
+	defer s.Close()
+
+	c, err := remote.Dial("tcp", l.Addr().String(), 0)
+	require.NoError(t, err)
+
+	defer c.Close()
+
+	assert.Equal(t, long, c.Lookup("10.2.3.4"))
+	assert.Equal(t, "short", c.Lookup("10.1.2.3"))
+}
+
+// stalledLookuper blocks batch lookups until release is closed.
+type stalledLookuper struct {
+	netrie.IPLookuper
+	release chan struct{}
+}
+
+func (s stalledLookuper) LookupBatch(ips []netip.Addr, out []string) {
+	<-s.release
+	netrie.LookupBatch(s.IPLookuper, ips, out)
+}
+
+func TestClient_timeout(t *testing.T) {
+	idx := netrie.NewCIDRIndex()
+	require.NoError(t, idx.AddCIDR("10.0.0.0/8", "foo"))
+
+	stalled := stalledLookuper{IPLookuper: idx, release: make(chan struct{})}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	s := remote.NewServer([]netrie.IPLookuper{stalled})
+
+	go func() {
+		_ = s.Serve(l)
+	}()
+
+	defer s.Close()
+	defer close(stalled.release)
+
+	c, err := remote.Dial("tcp", l.Addr().String(), 0, func(o *remote.ClientOptions) {
+		o.RequestTimeout = 50 * time.Millisecond
+	})
+	require.NoError(t, err)
+
+	defer c.Close()
+
+	out := make([]string, 1)
+	ips := []netip.Addr{netip.MustParseAddr("10.1.2.3")}
+
+	err = c.LookupBatchErr(ips, out)
+	require.ErrorIs(t, err, os.ErrDeadlineExceeded)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err = c.LookupBatchContext(ctx, ips, out)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	err = c.LookupBatchContext(ctx, ips, out)
+	require.ErrorIs(t, err, context.Canceled)
+
+	// Server that never responds to Dial.
+	silent, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	defer silent.Close()
+
+	_, err = remote.Dial("tcp", silent.Addr().String(), 0, func(o *remote.ClientOptions) {
+		o.RequestTimeout = 50 * time.Millisecond
+	})
+	require.ErrorIs(t, err, os.ErrDeadlineExceeded)
+}
+
+func BenchmarkClient_LookupBatch(b *testing.B) {
+	_, address := startServer(b, "tcp", "127.0.0.1:0")
+
+	c, err := remote.Dial("tcp", address, 0)
+	require.NoError(b, err)
+
+	defer c.Close()
+
+	ips := make([]netip.Addr, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		ips = append(ips, netip.AddrFrom4([4]byte{81, 2, byte(i % 8), byte(i)}))
+	}
+
+	out := make([]string, len(ips))
+
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		require.NoError(b, c.LookupBatchErr(ips, out))
+	}
+}
@@ -0,0 +1,207 @@
+package remote
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"sync"
+
+	"github.com/vearutop/netrie"
+)
+
+// ServerOptions configures Server.
+type ServerOptions struct {
+	MaxFrameSize int // Default DefaultMaxFrameSize.
+}
+
+// Server serves lookups from indexes, index id is the position in the list passed to NewServer.
+type Server struct {
+	opts    ServerOptions
+	indexes []netrie.IPLookuper
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	closed    bool
+	wg        sync.WaitGroup
+}
+
+// NewServer creates a Server for the indexes.
+func NewServer(indexes []netrie.IPLookuper, opts ...func(o *ServerOptions)) *Server {
+	s := &Server{
+		indexes:   indexes,
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[net.Conn]struct{}),
+	}
+
+	s.opts.MaxFrameSize = DefaultMaxFrameSize
+
+	for _, opt := range opts {
+		opt(&s.opts)
+	}
+
+	return s
+}
+
+// Serve accepts connections on l until it fails or server is closed.
+// Works with TCP and Unix socket listeners.
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+
+		return net.ErrClosed
+	}
+	s.listeners[l] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.listeners, l)
+		s.mu.Unlock()
+	}()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+
+			if closed {
+				return nil
+			}
+
+			return err
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			_ = conn.Close()
+
+			return nil
+		}
+
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		go s.serveConn(conn)
+	}
+}
+
+// Close stops listeners and closes active connections, it does not close indexes.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+
+	var errs []error
+
+	for l := range s.listeners {
+		errs = append(errs, l.Close())
+	}
+
+	for c := range s.conns {
+		_ = c.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer func() {
+		_ = conn.Close()
+
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+
+		s.wg.Done()
+	}()
+
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+
+	var (
+		req   []byte
+		resp  []byte
+		ips   []netip.Addr
+		names []string
+		err   error
+	)
+
+	for {
+		req, err = readFrame(r, s.opts.MaxFrameSize, req)
+		if err != nil {
+			return
+		}
+
+		resp, ips, names = s.handle(req, resp[:0], ips, names)
+
+		if len(resp) > s.opts.MaxFrameSize {
+			resp = appendError(resp[:0], fmt.Errorf("%w: response of %d bytes", errFrameTooLarge, len(resp)))
+		}
+
+		if err := writeFrame(w, resp); err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) handle(req, resp []byte, ips []netip.Addr, names []string) ([]byte, []netip.Addr, []string) {
+	if len(req) < 3 {
+		return appendError(resp, errors.New("short request")), ips, names
+	}
+
+	id := int(binary.BigEndian.Uint16(req[1:3]))
+	if id >= len(s.indexes) {
+		return appendError(resp, fmt.Errorf("unknown index %d", id)), ips, names
+	}
+
+	l := s.indexes[id]
+
+	switch req[0] {
+	case msgLookup:
+		var err error
+
+		ips, err = parseLookupRequest(req[3:], ips)
+		if err != nil {
+			return appendError(resp, err), ips, names
+		}
+
+		if cap(names) < len(ips) {
+			names = make([]string, len(ips))
+		}
+
+		names = names[:len(ips)]
+		netrie.LookupBatch(l, ips, names)
+
+		return appendLookupResponse(resp, names), ips, names
+	case msgInfo:
+		inf := info{Metadata: l.Metadata(), Len: l.Len(), LenNames: l.LenNames()}
+
+		if mp, ok := l.(netrie.MemoryUsageProvider); ok {
+			m := mp.MemoryUsage()
+			inf.Memory = &m
+		}
+
+		j, err := json.Marshal(inf)
+		if err != nil {
+			return appendError(resp, err), ips, names
+		}
+
+		resp = append(resp, statusOK)
+
+		return append(resp, j...), ips, names
+	default:
+		return appendError(resp, fmt.Errorf("unknown message type %d", req[0])), ips, names
+	}
+}
@@ -0,0 +1,44 @@
+package netrie
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// fileResident returns the number of bytes of the file in page cache, or 0 if it is unknown.
+func fileResident(f *os.File) int64 {
+	st, err := f.Stat()
+	if err != nil || st.Size() == 0 {
+		return 0
+	}
+
+	// Mapping does not load pages, mincore reports pages that are already cached.
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(st.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return 0
+	}
+
+	defer func() {
+		_ = syscall.Munmap(data)
+	}()
+
+	pageSize := os.Getpagesize()
+	vec := make([]byte, (len(data)+pageSize-1)/pageSize)
+
+	_, _, errno := syscall.Syscall(syscall.SYS_MINCORE,
+		uintptr(unsafe.Pointer(&data[0])), uintptr(len(data)), uintptr(unsafe.Pointer(&vec[0])))
+	if errno != 0 {
+		return 0
+	}
+
+	resident := int64(0)
+
+	for _, v := range vec {
+		if v&1 != 0 {
+			resident += int64(pageSize)
+		}
+	}
+
+	return min(resident, st.Size())
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package netrie
+
+import "os"
+
+// fileResident returns 0 as page cache residency is only available on Linux.
+func fileResident(_ *os.File) int64 {
+	return 0
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Client performs requests to Server.
+type Client struct {
+	BaseURL    string       // Server URL, e.g. "http://localhost:8080".
+	HTTPClient *http.Client // Default http.DefaultClient.
+	WithPrefix bool         // Request matched prefixes.
+}
+
+// NewClient creates Client for the base URL.
+func NewClient(baseURL string) *Client {
+	return &Client{BaseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+// Lookup resolves a single IP in the index.
+func (c *Client) Lookup(ctx context.Context, index, ip string) (Result, error) {
+	var res Result
+
+	err := c.do(ctx, http.MethodGet, "/lookup/"+url.PathEscape(index)+"/"+url.PathEscape(ip)+c.query(), nil, &res)
+
+	return res, err
+}
+
+// LookupBatch resolves multiple IPs in the index, results are in the order of ips.
+func (c *Client) LookupBatch(ctx context.Context, index string, ips []string) ([]Result, error) {
+	var res []Result
+
+	err := c.do(ctx, http.MethodPost, "/lookup/"+url.PathEscape(index)+c.query(), ips, &res)
+
+	return res, err
+}
+
+// Metadata returns information about all served indexes.
+func (c *Client) Metadata(ctx context.Context) ([]IndexInfo, error) {
+	var res []IndexInfo
+
+	err := c.do(ctx, http.MethodGet, "/metadata", nil, &res)
+
+	return res, err
+}
+
+// Ready returns nil if server is ready to serve all registered indexes.
+func (c *Client) Ready(ctx context.Context) error {
+	return c.do(ctx, http.MethodGet, "/ready", nil, nil)
+}
+
+func (c *Client) query() string {
+	if c.WithPrefix {
+		return "?prefix=1"
+	}
+
+	return ""
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body, res any) error {
+	var reqBody io.Reader
+
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("encode request: %w", err)
+		}
+
+		reqBody = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(c.BaseURL, "/")+path, reqBody)
+	if err != nil {
+		return err
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+
+	resp, err := hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Error string `json:"error"`
+		}
+
+		if json.Unmarshal(respBody, &e) == nil && e.Error != "" {
+			return fmt.Errorf("bad HTTP status code %d: %s", resp.StatusCode, e.Error)
+		}
+
+		return fmt.Errorf("bad HTTP status code: %d", resp.StatusCode)
+	}
+
+	if res == nil {
+		return nil
+	}
+
+	if err := json.Unmarshal(respBody, res); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+
+	return nil
+}
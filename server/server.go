@@ -0,0 +1,435 @@
+// Package server exposes named IP lookupers over HTTP.
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/vearutop/netrie"
+)
+
+// Result is a lookup result for a single IP.
+type Result struct {
+	IP     string `json:"ip"`
+	Name   string `json:"name"`
+	Prefix string `json:"prefix,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// IndexInfo describes a served index.
+type IndexInfo struct {
+	Index    string           `json:"index"`
+	Metadata *netrie.Metadata `json:"metadata,omitempty"`
+	Len      int              `json:"len"`
+	LenNames int              `json:"len_names"`
+
+	// Memory is reported by indexes that implement netrie.MemoryUsageProvider.
+	Memory *netrie.MemoryUsage `json:"memory,omitempty"`
+}
+
+// Options configures Server.
+type Options struct {
+	MaxBatchSize int   // Default 10000.
+	MaxBodySize  int64 // Default 1 MiB.
+}
+
+// Server is an http.Handler that serves lookups from named indexes.
+//
+// Routes:
+//   - GET /lookup/{index}/{ip}, add ?prefix=1 to include matched prefix,
+//   - POST /lookup/{index} with a JSON array of IPs, add ?prefix=1 to include matched prefixes,
+//   - GET /metadata, GET /metadata/{index},
+//   - GET /health for liveness and GET /ready for readiness.
+type Server struct {
+	opts Options
+	mux  *http.ServeMux
+
+	mu      sync.RWMutex
+	indexes map[string]*holder
+	loaders map[string]func() (netrie.IPLookuper, error)
+}
+
+// holder keeps a lookuper alive while requests use it.
+type holder struct {
+	l  netrie.IPLookuper
+	wg sync.WaitGroup
+}
+
+// New creates an empty Server.
+func New(opts ...func(o *Options)) *Server {
+	s := &Server{
+		indexes: make(map[string]*holder),
+		loaders: make(map[string]func() (netrie.IPLookuper, error)),
+	}
+
+	s.opts.MaxBatchSize = 10000
+	s.opts.MaxBodySize = 1 << 20
+
+	for _, opt := range opts {
+		opt(&s.opts)
+	}
+
+	s.mux = http.NewServeMux()
+	s.mux.HandleFunc("GET /lookup/{index}/{ip}", s.lookup)
+	s.mux.HandleFunc("POST /lookup/{index}", s.lookupBatch)
+	s.mux.HandleFunc("GET /metadata", s.metadata)
+	s.mux.HandleFunc("GET /metadata/{index}", s.metadata)
+	s.mux.HandleFunc("GET /health", s.health)
+	s.mux.HandleFunc("GET /ready", s.ready)
+
+	return s
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Set serves l as index name, previous lookuper with the same name is closed once in-flight requests are done.
+func (s *Server) Set(name string, l netrie.IPLookuper) {
+	s.mu.Lock()
+	prev := s.indexes[name]
+	s.indexes[name] = &holder{l: l}
+	s.mu.Unlock()
+
+	s.release(prev)
+}
+
+// Add registers a loader for index name and loads it.
+// Loader is called again on Reload, the index is reported as not ready while it fails to load.
+func (s *Server) Add(name string, load func() (netrie.IPLookuper, error)) error {
+	s.mu.Lock()
+	s.loaders[name] = load
+	s.mu.Unlock()
+
+	return s.Reload(name)
+}
+
+// AddFile registers an index that is loaded from a file with netrie.OpenFile.
+func (s *Server) AddFile(name, fn string, opts ...func(o *netrie.Options)) error {
+	return s.Add(name, func() (netrie.IPLookuper, error) {
+		return netrie.OpenFile(fn, opts...)
+	})
+}
+
+// Reload calls loaders of named indexes (or all indexes if no names are given) and replaces served lookupers.
+// Failed index keeps serving its previous lookuper, if any.
+func (s *Server) Reload(names ...string) error {
+	s.mu.RLock()
+	if len(names) == 0 {
+		for name := range s.loaders {
+			names = append(names, name)
+		}
+	}
+
+	loaders := make(map[string]func() (netrie.IPLookuper, error), len(names))
+
+	for _, name := range names {
+		if load, ok := s.loaders[name]; ok {
+			loaders[name] = load
+		}
+	}
+	s.mu.RUnlock()
+
+	var errs []error
+
+	for _, name := range names {
+		load, ok := loaders[name]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: no loader", name))
+
+			continue
+		}
+
+		l, err := load()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+
+			continue
+		}
+
+		s.Set(name, l)
+	}
+
+	return errors.Join(errs...)
+}
+
+// Remove stops serving index name and closes its lookuper once in-flight requests are done.
+func (s *Server) Remove(name string) {
+	s.mu.Lock()
+	prev := s.indexes[name]
+	delete(s.indexes, name)
+	delete(s.loaders, name)
+	s.mu.Unlock()
+
+	s.release(prev)
+}
+
+// Close stops serving all indexes and closes them.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	indexes := s.indexes
+	s.indexes = make(map[string]*holder)
+	s.loaders = make(map[string]func() (netrie.IPLookuper, error))
+	s.mu.Unlock()
+
+	var errs []error
+
+	for _, h := range indexes {
+		h.wg.Wait()
+
+		if err := h.l.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (s *Server) release(h *holder) {
+	if h == nil {
+		return
+	}
+
+	go func() {
+		h.wg.Wait()
+		_ = h.l.Close()
+	}()
+}
+
+// acquire returns named lookuper and a function to release it.
+func (s *Server) acquire(name string) (netrie.IPLookuper, func(), bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	h, ok := s.indexes[name]
+	if !ok {
+		return nil, nil, false
+	}
+
+	h.wg.Add(1)
+
+	return h.l, h.wg.Done, true
+}
+
+func (s *Server) lookup(w http.ResponseWriter, r *http.Request) {
+	l, done, ok := s.acquire(r.PathValue("index"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "index not found")
+
+		return
+	}
+	defer done()
+
+	ipStr := r.PathValue("ip")
+
+	addr, err := netip.ParseAddr(ipStr)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid IP")
+
+		return
+	}
+
+	res := Result{IP: ipStr}
+
+	if withPrefix(r) {
+		lookupPrefix(l, addr, &res)
+	} else {
+		name, err := l.SafeLookupIP(addr.AsSlice())
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+
+			return
+		}
+
+		res.Name = name
+	}
+
+	if res.Error != "" {
+		writeError(w, http.StatusInternalServerError, res.Error)
+
+		return
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (s *Server) lookupBatch(w http.ResponseWriter, r *http.Request) {
+	l, done, ok := s.acquire(r.PathValue("index"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "index not found")
+
+		return
+	}
+	defer done()
+
+	var ips []string
+
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, s.opts.MaxBodySize)).Decode(&ips); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid body, JSON array of IPs expected: "+err.Error())
+
+		return
+	}
+
+	if len(ips) > s.opts.MaxBatchSize {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("too many IPs, max %d", s.opts.MaxBatchSize))
+
+		return
+	}
+
+	res := make([]Result, len(ips))
+	addrs := make([]netip.Addr, len(ips))
+
+	for i, ipStr := range ips {
+		res[i].IP = ipStr
+
+		addr, err := netip.ParseAddr(ipStr)
+		if err != nil {
+			res[i].Error = "invalid IP"
+
+			continue
+		}
+
+		addrs[i] = addr
+	}
+
+	if withPrefix(r) {
+		for i, addr := range addrs {
+			if addr.IsValid() {
+				lookupPrefix(l, addr, &res[i])
+			}
+		}
+	} else {
+		names := make([]string, len(addrs))
+		netrie.LookupBatch(l, addrs, names)
+
+		for i, name := range names {
+			res[i].Name = name
+		}
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (s *Server) metadata(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if name := r.PathValue("index"); name != "" {
+		h, ok := s.indexes[name]
+		if !ok {
+			writeError(w, http.StatusNotFound, "index not found")
+
+			return
+		}
+
+		writeJSON(w, http.StatusOK, indexInfo(name, h.l))
+
+		return
+	}
+
+	res := make([]IndexInfo, 0, len(s.indexes))
+	for name, h := range s.indexes {
+		res = append(res, indexInfo(name, h.l))
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Index < res[j].Index
+	})
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (s *Server) health(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (s *Server) ready(w http.ResponseWriter, _ *http.Request) {
+	s.mu.RLock()
+	var missing []string
+
+	for name := range s.loaders {
+		if _, ok := s.indexes[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+
+	empty := len(s.indexes) == 0
+	s.mu.RUnlock()
+
+	switch {
+	case len(missing) > 0:
+		slices.Sort(missing)
+		writeJSON(w, http.StatusServiceUnavailable, map[string]any{"status": "not ready", "missing": missing})
+	case empty:
+		writeJSON(w, http.StatusServiceUnavailable, map[string]any{"status": "not ready"})
+	default:
+		writeJSON(w, http.StatusOK, map[string]any{"status": "ready"})
+	}
+}
+
+func indexInfo(name string, l netrie.IPLookuper) IndexInfo {
+	info := IndexInfo{
+		Index:    name,
+		Metadata: l.Metadata(),
+		Len:      l.Len(),
+		LenNames: l.LenNames(),
+	}
+
+	if mp, ok := l.(netrie.MemoryUsageProvider); ok {
+		m := mp.MemoryUsage()
+		info.Memory = &m
+	}
+
+	return info
+}
+
+func withPrefix(r *http.Request) bool {
+	v := r.URL.Query().Get("prefix")
+
+	return v != "" && v != "0" && v != "false"
+}
+
+func lookupPrefix(l netrie.IPLookuper, addr netip.Addr, res *Result) {
+	pl, ok := l.(netrie.PrefixLookuper)
+	if !ok {
+		name, err := l.SafeLookupIP(addr.AsSlice())
+		if err != nil {
+			res.Error = err.Error()
+		}
+
+		res.Name = name
+
+		return
+	}
+
+	name, prefix, err := pl.LookupPrefix(addr)
+	if err != nil {
+		res.Error = err.Error()
+
+		return
+	}
+
+	res.Name = name
+
+	if prefix.IsValid() {
+		res.Prefix = prefix.String()
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,112 @@
+package server_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vearutop/netrie"
+	"github.com/vearutop/netrie/server"
+)
+
+func TestServer(t *testing.T) {
+	s := server.New()
+	defer s.Close()
+
+	require.NoError(t, s.AddFile("cities", "../testdata/cities.bin"))
+
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	ctx := context.Background()
+	c := server.NewClient(srv.URL)
+
+	require.NoError(t, c.Ready(ctx))
+
+	res, err := c.Lookup(ctx, "cities", "81.2.69.145")
+	require.NoError(t, err)
+	assert.Equal(t, server.Result{IP: "81.2.69.145", Name: "GB:London"}, res)
+
+	c.WithPrefix = true
+
+	res, err = c.Lookup(ctx, "cities", "2001:480:10::1")
+	require.NoError(t, err)
+	assert.Equal(t, "US:San Diego", res.Name)
+	assert.NotEmpty(t, res.Prefix)
+
+	_, err = c.Lookup(ctx, "cities", "invalid")
+	require.EqualError(t, err, "bad HTTP status code 400: invalid IP")
+
+	_, err = c.Lookup(ctx, "unknown", "81.2.69.145")
+	require.EqualError(t, err, "bad HTTP status code 404: index not found")
+
+	c.WithPrefix = false
+
+	batch, err := c.LookupBatch(ctx, "cities", []string{"2.125.160.217", "invalid", "143.198.196.44", "81.2.69.145"})
+	require.NoError(t, err)
+	assert.Equal(t, []server.Result{
+		{IP: "2.125.160.217", Name: "GB:Boxford"},
+		{IP: "invalid", Error: "invalid IP"},
+		{IP: "143.198.196.44"},
+		{IP: "81.2.69.145", Name: "GB:London"},
+	}, batch)
+
+	meta, err := c.Metadata(ctx)
+	require.NoError(t, err)
+	require.Len(t, meta, 1)
+	assert.Equal(t, "cities", meta[0].Index)
+	assert.Equal(t, 250, meta[0].Len)
+	assert.Equal(t, 55, meta[0].LenNames)
+	assert.Equal(t, "2025-08-12 17:49:01 +0000 UTC", meta[0].Metadata.BuildDate.String())
+	require.NotNil(t, meta[0].Memory)
+	assert.Positive(t, meta[0].Memory.Total())
+
+	// Hot reload replaces the index.
+	tr := netrie.NewCIDRIndex()
+	require.NoError(t, tr.AddCIDR("81.2.69.0/24", "replaced"))
+	s.Set("cities", tr)
+
+	res, err = c.Lookup(ctx, "cities", "81.2.69.145")
+	require.NoError(t, err)
+	assert.Equal(t, "replaced", res.Name)
+
+	require.NoError(t, s.Reload())
+
+	res, err = c.Lookup(ctx, "cities", "81.2.69.145")
+	require.NoError(t, err)
+	assert.Equal(t, "GB:London", res.Name)
+}
+
+func TestServer_ready(t *testing.T) {
+	s := server.New()
+	defer s.Close()
+
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	c := server.NewClient(srv.URL)
+	require.Error(t, c.Ready(context.Background()))
+
+	require.Error(t, s.AddFile("missing", "../testdata/missing.bin"))
+	require.Error(t, c.Ready(context.Background()))
+
+	resp, err := http.Get(srv.URL + "/health")
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	s.Remove("missing")
+	require.NoError(t, s.Add("cities", func() (netrie.IPLookuper, error) {
+		return netrie.LoadFromFile("../testdata/cities.bin")
+	}))
+	require.NoError(t, c.Ready(context.Background()))
+
+	resp, err = http.Post(srv.URL+"/lookup/cities", "application/json", strings.NewReader("{"))
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
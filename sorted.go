@@ -0,0 +1,237 @@
+package netrie
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+)
+
+// ErrUnsorted is returned by SortedBuilder when a prefix is added out of order.
+var ErrUnsorted = errors.New("prefixes are not sorted")
+
+// ComparePrefixes compares prefixes by address bits as they are stored in the trie,
+// a prefix is followed by the more specific prefixes it contains.
+// IPv4 addresses are compared as 32 bits, IPv6 as 128 bits, so IPv4 and IPv6 prefixes may interleave.
+// IPv4-mapped IPv6 prefixes of at least 96 bits are compared as IPv4 prefixes.
+func ComparePrefixes(a, b netip.Prefix) int {
+	a, b = unmapPrefix(a), unmapPrefix(b)
+	ab, bb := prefixBytes(a), prefixBytes(b)
+
+	for i := 0; i < min(a.Bits(), b.Bits()); i++ {
+		x, y := bitAt(ab, i), bitAt(bb, i)
+
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	}
+
+	return a.Bits() - b.Bits()
+}
+
+// unmapPrefix converts IPv4-mapped IPv6 prefix of at least 96 bits to IPv4 prefix.
+func unmapPrefix(p netip.Prefix) netip.Prefix {
+	if p.Addr().Is4In6() && p.Bits() >= 96 {
+		return netip.PrefixFrom(p.Addr().Unmap(), p.Bits()-96)
+	}
+
+	return p
+}
+
+func prefixBytes(p netip.Prefix) []byte {
+	if p.Addr().Is4() {
+		b := p.Addr().As4()
+
+		return b[:]
+	}
+
+	b := p.Addr().As16()
+
+	return b[:]
+}
+
+func bitAt(ip []byte, i int) byte {
+	return (ip[i/8] >> (7 - (i % 8))) & 1
+}
+
+// SortedBuilder builds a minimal index from prefixes added in order of ComparePrefixes.
+//
+// Nodes are registered as soon as no following prefix can change them, duplicate nodes are dropped,
+// so that memory is proportional to the minimal DAWG instead of the full trie.
+// Duplicate prefix replaces the name of the previous one.
+type SortedBuilder[S int16 | int32] struct {
+	idx      *CIDRIndex[S]
+	register map[minimizeSig[S]]int32
+
+	// path holds unregistered nodes of the last prefix starting from root.
+	path    []int32
+	prev    []byte
+	prevLen int
+
+	garbage int
+	err     error
+}
+
+// NewSortedBuilder creates a SortedBuilder of CIDRIndex for up to 2^16 networks.
+func NewSortedBuilder() *SortedBuilder[int16] {
+	return newSortedBuilder(NewCIDRIndex())
+}
+
+// NewLargeSortedBuilder creates a SortedBuilder of CIDRIndex for up to 2^32 networks.
+func NewLargeSortedBuilder() *SortedBuilder[int32] {
+	return newSortedBuilder(NewCIDRLargeIndex())
+}
+
+func newSortedBuilder[S int16 | int32](idx *CIDRIndex[S]) *SortedBuilder[S] {
+	return &SortedBuilder[S]{
+		idx:      idx,
+		register: make(map[minimizeSig[S]]int32),
+		path:     []int32{0},
+	}
+}
+
+// Metadata returns a reference to the Metadata of the index.
+func (b *SortedBuilder[S]) Metadata() *Metadata {
+	return b.idx.Metadata()
+}
+
+// Err returns the first error that occurred in AddNet.
+func (b *SortedBuilder[S]) Err() error {
+	return b.err
+}
+
+// AddNet adds a CIDR block with an associated name, error is available with Err.
+func (b *SortedBuilder[S]) AddNet(ipNet *net.IPNet, name string) {
+	p, err := netPrefix(ipNet, name)
+	if err == nil {
+		err = b.AddPrefix(p, name)
+	}
+
+	if err != nil && b.err == nil {
+		b.err = err
+	}
+}
+
+// AddCIDR adds a CIDR with an associated name.
+// Returns error if CIDR is invalid or not sorted.
+func (b *SortedBuilder[S]) AddCIDR(cidr string, name string) error {
+	p, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return fmt.Errorf("invalid CIDR (%s): %v", name, cidr)
+	}
+
+	return b.AddPrefix(p, name)
+}
+
+// AddPrefix adds a prefix with an associated name, IPv4-mapped IPv6 prefix is added as IPv4 prefix.
+// Returns ErrUnsorted if prefix precedes the previous one.
+func (b *SortedBuilder[S]) AddPrefix(p netip.Prefix, name string) error {
+	p, err := checkPrefix(p, name)
+	if err != nil {
+		return err
+	}
+
+	ip := prefixBytes(p)
+	bits := p.Bits()
+
+	// Length of common path with the previous prefix.
+	common := 0
+	for common < min(bits, b.prevLen) && bitAt(ip, common) == bitAt(b.prev, common) {
+		common++
+	}
+
+	switch {
+	case common == bits && common == b.prevLen:
+		// Same prefix.
+	case common == bits, common < b.prevLen && bitAt(ip, common) < bitAt(b.prev, common):
+		return fmt.Errorf("%w: %s after %s", ErrUnsorted, p, b.lastPrefix())
+	}
+
+	idx := b.idx
+
+	id, err := idx.nameID(name)
+	if err != nil {
+		return err
+	}
+
+	b.registerPath(common)
+	current := b.path[len(b.path)-1]
+
+	for i := common; i < bits; i++ {
+		idx.nodes[current].children[bitAt(ip, i)] = int32(len(idx.nodes))
+		current = int32(len(idx.nodes))
+		idx.nodes = append(idx.nodes, trieNode[S]{children: [2]int32{-1, -1}, id: -1, maskLen: -1})
+		b.path = append(b.path, current)
+	}
+
+	if idx.nodes[current].id == -1 {
+		idx.total++
+	}
+
+	idx.nodes[current].id = id
+	idx.nodes[current].maskLen = int8(bits)
+
+	b.prev = append(b.prev[:0], ip...)
+	b.prevLen = bits
+
+	return nil
+}
+
+func (b *SortedBuilder[S]) lastPrefix() netip.Prefix {
+	addr, _ := netip.AddrFromSlice(b.prev)
+
+	return netip.PrefixFrom(addr, b.prevLen)
+}
+
+// registerPath registers nodes of the path deeper than depth, replacing them with equivalent registered nodes.
+func (b *SortedBuilder[S]) registerPath(depth int) {
+	idx := b.idx
+
+	for d := len(b.path) - 1; d > depth; d-- {
+		i := b.path[d]
+		node := idx.nodes[i]
+		s := minimizeSig[S]{ch0: node.children[0], ch1: node.children[1], id: node.id, maskLen: node.maskLen}
+
+		r, ok := b.register[s]
+		if !ok {
+			b.register[s] = i
+
+			continue
+		}
+
+		parent := &idx.nodes[b.path[d-1]]
+		if parent.children[0] == i {
+			parent.children[0] = r
+		} else {
+			parent.children[1] = r
+		}
+
+		// Unregistered nodes are usually at the end.
+		if int(i) == len(idx.nodes)-1 {
+			idx.nodes = idx.nodes[:i]
+		} else {
+			b.garbage++
+		}
+	}
+
+	b.path = b.path[:depth+1]
+}
+
+// Finish registers remaining nodes and returns minimized index.
+// Builder should not be used after Finish.
+func (b *SortedBuilder[S]) Finish() *CIDRIndex[S] {
+	b.registerPath(0)
+	b.register = nil
+
+	if b.garbage > 0 {
+		// Remove unreachable nodes.
+		b.idx.Minimize()
+	}
+
+	b.idx.shared = true
+
+	return b.idx
+}
@@ -0,0 +1,179 @@
+package netrie_test
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/netip"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vearutop/netrie"
+)
+
+func TestSortedBuilder(t *testing.T) {
+	rnd := rand.New(rand.NewSource(3))
+	prefixes := make([]netip.Prefix, 0, 20000)
+
+	for i := 0; i < cap(prefixes); i++ {
+		var addr netip.Addr
+
+		if i%4 == 0 {
+			addr = netip.AddrFrom16([16]byte{0x20, 0x01, 0x0d, byte(rnd.Intn(4)), byte(rnd.Intn(256))})
+		} else {
+			addr = netip.AddrFrom4([4]byte{byte(rnd.Intn(64)), byte(rnd.Intn(256)), byte(rnd.Intn(256))})
+		}
+
+		bits := 12 + rnd.Intn(13)
+		if addr.Is6() {
+			bits = 24 + rnd.Intn(17)
+		}
+
+		prefixes = append(prefixes, netip.PrefixFrom(addr, bits).Masked())
+	}
+
+	slices.SortStableFunc(prefixes, netrie.ComparePrefixes)
+
+	names := make([]string, len(prefixes))
+	for i := range names {
+		names[i] = fmt.Sprintf("n%d", rnd.Intn(20))
+	}
+
+	b := netrie.NewSortedBuilder()
+	idx := netrie.NewCIDRIndex()
+
+	for i, p := range prefixes {
+		require.NoError(t, b.AddPrefix(p, names[i]))
+		require.NoError(t, idx.AddCIDR(p.String(), names[i]))
+	}
+
+	sorted := b.Finish()
+	idx.Minimize()
+
+	assert.Equal(t, idx.LenNodes(), sorted.LenNodes())
+	assert.Equal(t, idx.LenNames(), sorted.LenNames())
+
+	for range 20000 {
+		ip := netip.AddrFrom4([4]byte{byte(rnd.Intn(64)), byte(rnd.Intn(256)), byte(rnd.Intn(256)), byte(rnd.Intn(256))})
+		require.Equal(t, idx.Lookup(ip.String()), sorted.Lookup(ip.String()), ip)
+
+		ip = netip.AddrFrom16([16]byte{0x20, 0x01, 0x0d, byte(rnd.Intn(4)), byte(rnd.Intn(256)), byte(rnd.Intn(256))})
+		require.Equal(t, idx.Lookup(ip.String()), sorted.Lookup(ip.String()), ip)
+	}
+}
+
+func TestSortedBuilder_unsorted(t *testing.T) {
+	b := netrie.NewSortedBuilder()
+
+	require.NoError(t, b.AddCIDR("10.0.0.0/8", "a"))
+	require.NoError(t, b.AddCIDR("10.1.0.0/16", "b"))
+	require.NoError(t, b.AddCIDR("10.1.0.0/16", "c"))
+	require.NoError(t, b.AddCIDR("10.2.0.0/16", "a"))
+
+	assert.EqualError(t, b.AddCIDR("10.1.0.0/16", "d"), "prefixes are not sorted: 10.1.0.0/16 after 10.2.0.0/16")
+	assert.ErrorIs(t, b.AddCIDR("10.0.0.0/8", "d"), netrie.ErrUnsorted)
+
+	b.AddNet(mustNet(t, "10.2.0.0/15"), "e")
+	require.ErrorIs(t, b.Err(), netrie.ErrUnsorted)
+
+	require.NoError(t, b.AddCIDR("10.2.128.0/17", "f"))
+
+	idx := b.Finish()
+	assert.Equal(t, 4, idx.Len())
+	assert.Equal(t, "a", idx.Lookup("10.0.0.1"))
+	assert.Equal(t, "c", idx.Lookup("10.1.0.1"))
+	assert.Equal(t, "a", idx.Lookup("10.2.0.1"))
+	assert.Equal(t, "f", idx.Lookup("10.2.200.1"))
+}
+
+func TestSortedBuilder_mapped(t *testing.T) {
+	mapped := netip.MustParsePrefix("::ffff:10.0.0.0/104")
+
+	assert.Equal(t, 0, netrie.ComparePrefixes(mapped, netip.MustParsePrefix("10.0.0.0/8")))
+	assert.Negative(t, netrie.ComparePrefixes(mapped, netip.MustParsePrefix("10.1.0.0/16")))
+	assert.Negative(t, netrie.ComparePrefixes(netip.MustParsePrefix("::ffff:0.0.0.0/80"), mapped)) // Compared as IPv6.
+
+	b := netrie.NewSortedBuilder()
+	require.NoError(t, b.AddPrefix(mapped, "a"))
+	require.NoError(t, b.AddCIDR("::ffff:10.1.0.0/112", "b"))
+	b.AddNet(mustNet(t, "::ffff:10.2.0.0/112"), "c")
+	require.NoError(t, b.Err())
+
+	assert.ErrorContains(t, b.AddPrefix(netip.MustParsePrefix("::ffff:0.0.0.0/80"), "d"), "invalid IPv4-mapped prefix")
+
+	idx := b.Finish()
+	assert.Equal(t, "a", idx.Lookup("10.3.0.1"))
+	assert.Equal(t, "b", idx.Lookup("10.1.0.1"))
+	assert.Equal(t, "c", idx.Lookup("::ffff:10.2.0.1"))
+}
+
+func TestCIDRIndex_mapped(t *testing.T) {
+	idx := netrie.NewCIDRIndex()
+	require.NoError(t, idx.AddCIDR("::ffff:10.0.0.0/104", "a"))
+	require.NoError(t, idx.AddCIDR("10.1.0.0/16", "b"))
+	idx.AddNet(mustNet(t, "::ffff:10.2.0.0/112"), "c")
+	require.NoError(t, idx.Err())
+
+	assert.Equal(t, 3, idx.Len())
+	assert.Equal(t, "a", idx.Lookup("10.3.0.1"))
+	assert.Equal(t, "b", idx.Lookup("::ffff:10.1.0.1"))
+	assert.Equal(t, "c", idx.Lookup("10.2.0.1"))
+
+	n, p, err := idx.LookupPrefix(netip.MustParseAddr("10.3.0.1"))
+	require.NoError(t, err)
+	assert.Equal(t, "a", n)
+	assert.Equal(t, "10.0.0.0/8", p.String())
+
+	require.ErrorContains(t, idx.AddCIDR("::ffff:0.0.0.0/80", "d"), "invalid IPv4-mapped prefix")
+	assert.Equal(t, 3, idx.LenNames())
+
+	idx.AddNet(&net.IPNet{IP: net.ParseIP("::ffff:0.0.0.0"), Mask: net.CIDRMask(80, 128)}, "d")
+	require.ErrorContains(t, idx.Err(), "invalid IPv4-mapped prefix")
+
+	b := netrie.NewBuilder(func(o *netrie.BuilderOptions) { o.Conflict = netrie.FailOnConflict })
+	require.NoError(t, b.AddCIDR("10.0.0.0/8", "a"))
+	require.ErrorIs(t, b.AddCIDR("::ffff:10.0.0.0/104", "b"), netrie.ErrConflict)
+	require.ErrorContains(t, b.AddCIDR("::ffff:0.0.0.0/80", "d"), "invalid IPv4-mapped prefix")
+	require.Len(t, b.Conflicts(), 1)
+	assert.Equal(t, "10.0.0.0/8", b.Conflicts()[0].Prefix.String())
+}
+
+func TestCIDRIndex_AddNet_afterMinimize(t *testing.T) {
+	idx := netrie.NewCIDRIndex()
+	require.NoError(t, idx.AddCIDR("10.0.0.0/16", "a"))
+	require.NoError(t, idx.AddCIDR("10.1.0.0/16", "a"))
+
+	idx.Minimize()
+
+	// Leaf nodes of both prefixes are shared.
+	require.NoError(t, idx.AddCIDR("10.0.1.0/24", "b"))
+
+	assert.Equal(t, "b", idx.Lookup("10.0.1.1"))
+	assert.Equal(t, "a", idx.Lookup("10.0.2.1"))
+	assert.Equal(t, "a", idx.Lookup("10.1.1.1"))
+
+	// Loaded index keeps track of shared nodes.
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, idx.Save(buf))
+
+	l, err := netrie.Load(buf)
+	require.NoError(t, err)
+
+	loaded, ok := l.(*netrie.CIDRIndex[int16])
+	require.True(t, ok)
+
+	require.NoError(t, loaded.AddCIDR("10.1.1.0/24", "c"))
+	assert.Equal(t, "b", loaded.Lookup("10.0.1.1"))
+	assert.Equal(t, "a", loaded.Lookup("10.0.2.1"))
+	assert.Equal(t, "c", loaded.Lookup("10.1.1.1"))
+	assert.Equal(t, "a", loaded.Lookup("10.1.2.1"))
+
+	// Unreachable copies are removed.
+	nodes := loaded.LenNodes()
+	loaded.Minimize()
+	assert.Less(t, loaded.LenNodes(), nodes)
+	assert.Equal(t, "c", loaded.Lookup("10.1.1.1"))
+}
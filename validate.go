@@ -0,0 +1,317 @@
+package netrie
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// ErrInvalidIndex is matched by errors.Is for every ValidationError.
+var ErrInvalidIndex = errors.New("invalid index")
+
+// ValidationError describes a malformed part of index data.
+type ValidationError struct {
+	Section string // Section of index data: header, nodes or names.
+	Index   int64  // Index of invalid node or name, -1 if not applicable.
+	Err     error
+}
+
+func (e *ValidationError) Error() string {
+	if e.Index >= 0 {
+		return fmt.Sprintf("%s: %s[%d]: %v", ErrInvalidIndex, e.Section, e.Index, e.Err)
+	}
+
+	return fmt.Sprintf("%s: %s: %v", ErrInvalidIndex, e.Section, e.Err)
+}
+
+// Unwrap allows matching ErrInvalidIndex and the underlying error.
+func (e *ValidationError) Unwrap() []error {
+	return []error{ErrInvalidIndex, e.Err}
+}
+
+func invalid(section string, index int64, format string, args ...any) *ValidationError {
+	return &ValidationError{Section: section, Index: index, Err: fmt.Errorf(format, args...)}
+}
+
+// Validate checks that index data is well-formed: sections fit the data, child indices are in bounds,
+// nodes form an acyclic graph, name ids are within names, and mask lengths match node depths.
+// Returns *ValidationError for malformed data.
+func Validate(r io.ReaderAt) error {
+	strict := func(o *Options) {
+		o.Strict = true
+	}
+
+	// Index is not closed, as it would close r.
+	_, err := Open(r, strict)
+
+	if errors.Is(err, ErrCompressedStream) {
+		size, ok := readerSize(r)
+		if !ok {
+			size = math.MaxInt64
+		}
+
+		_, err = Load(io.NewSectionReader(r, 0, size), strict)
+	}
+
+	return err
+}
+
+// readerSize returns the size of data if r can report it.
+func readerSize(r io.ReaderAt) (int64, bool) {
+	switch v := r.(type) {
+	case interface{ Size() int64 }:
+		return v.Size(), true
+	case *os.File:
+		st, err := v.Stat()
+		if err != nil || !st.Mode().IsRegular() {
+			return 0, false
+		}
+
+		return st.Size(), true
+	}
+
+	return 0, false
+}
+
+// validateTrie checks reachable nodes using node to read them.
+func validateTrie[S int16 | int32](nodesLen, namesLen int64, node func(i int32) (trieNode[S], error)) error {
+	if nodesLen == 0 {
+		return invalid("nodes", -1, "no root node")
+	}
+
+	v := trieValidator[S]{
+		nodesLen: nodesLen,
+		namesLen: namesLen,
+		node:     node,
+		pinned:   make([]int16, nodesLen),
+		height:   make([]uint8, nodesLen),
+	}
+
+	for i := range v.pinned {
+		v.pinned[i] = notVisited
+	}
+
+	return v.visit(0, 0)
+}
+
+const notVisited = -2
+
+type trieValidator[S int16 | int32] struct {
+	nodesLen int64
+	namesLen int64
+	node     func(i int32) (trieNode[S], error)
+
+	// pinned is the depth of a visited node if its subtree has prefixes, -1 otherwise.
+	// Subtree without prefixes may be shared by nodes of different depths.
+	pinned []int16
+	height []uint8
+}
+
+// visit checks subtree of the node at depth, a cycle is detected as a path longer than 128 bits.
+func (v *trieValidator[S]) visit(i int32, depth int) error {
+	if depth > 128 {
+		return invalid("nodes", int64(i), "path is longer than 128 bits, nodes may form a cycle")
+	}
+
+	if p := v.pinned[i]; p != notVisited {
+		if p >= 0 && int(p) != depth {
+			return invalid("nodes", int64(i), "node is reachable at depths %d and %d", p, depth)
+		}
+
+		if depth+int(v.height[i]) > 128 {
+			return invalid("nodes", int64(i), "path is longer than 128 bits")
+		}
+
+		return nil
+	}
+
+	node, err := v.node(i)
+	if err != nil {
+		return err
+	}
+
+	pinned := node.id != -1
+
+	if pinned {
+		if node.id < 1 || int64(node.id) > v.namesLen {
+			return invalid("nodes", int64(i), "name id %d is out of range [1, %d]", node.id, v.namesLen)
+		}
+
+		// Mask length of /128 overflows to -128, same as when it is stored.
+		if node.maskLen != int8(depth) {
+			return invalid("nodes", int64(i), "mask length %d does not match depth %d", node.maskLen, depth)
+		}
+	}
+
+	height := 0
+
+	for _, c := range node.children {
+		if c == -1 {
+			continue
+		}
+
+		if c < 0 || int64(c) >= v.nodesLen {
+			return invalid("nodes", int64(i), "child %d is out of range [0, %d)", c, v.nodesLen)
+		}
+
+		if err := v.visit(c, depth+1); err != nil {
+			return err
+		}
+
+		height = max(height, int(v.height[c])+1)
+		pinned = pinned || v.pinned[c] >= 0
+	}
+
+	v.height[i] = uint8(height)
+	v.pinned[i] = -1
+
+	if pinned {
+		v.pinned[i] = int16(depth)
+	}
+
+	return nil
+}
+
+// validate checks nodes of loaded index.
+func (idx *CIDRIndex[S]) validate() error {
+	return validateTrie(int64(len(idx.nodes)), int64(len(idx.names)), func(i int32) (trieNode[S], error) {
+		return idx.nodes[i], nil
+	})
+}
+
+// validate checks sections and nodes of opened index, end is the size of uncompressed data or -1 if unknown.
+func (idx *CIDRIndexFile[S]) validate(end int64) error {
+	if end >= 0 && idx.namesOffset > end {
+		return invalid("nodes", -1, "%d nodes end at %d after end of data at %d", idx.nodesLen, idx.namesOffset, end)
+	}
+
+	// Every name takes at least a byte.
+	if end >= 0 && idx.namesOffset+idx.namesLen > end {
+		return invalid("names", -1, "%d names do not fit in %d bytes", idx.namesLen, end-idx.namesOffset)
+	}
+
+	namesEnd, err := idx.namesEnd(end)
+	if err != nil {
+		return err
+	}
+
+	if end >= 0 && namesEnd != end {
+		return invalid("names", -1, "names end at %d, data ends at %d", namesEnd, end)
+	}
+
+	b := make([]byte, idx.nodeSize)
+
+	return validateTrie(idx.nodesLen, idx.namesLen, func(i int32) (trieNode[S], error) {
+		node, err := idx.readNode(idx.r, int64(i), b)
+		if err != nil {
+			return node, &ValidationError{Section: "nodes", Index: int64(i), Err: err}
+		}
+
+		return node, nil
+	})
+}
+
+// namesEnd checks names section and returns its end offset.
+func (idx *CIDRIndexFile[S]) namesEnd(end int64) (int64, error) {
+	if end < 0 {
+		end = math.MaxInt64
+	}
+
+	read := func(off, n int64) ([]byte, error) {
+		if off+n > end {
+			return nil, fmt.Errorf("%d bytes at %d after end of data at %d", n, off, end)
+		}
+
+		return readFull(io.NewSectionReader(idx.r, off, n), n)
+	}
+
+	switch {
+	case idx.frontCoded:
+		return idx.frontCodedNamesEnd(io.NewSectionReader(idx.r, idx.namesOffset, end-idx.namesOffset), read)
+	case idx.namesDataOffset != 0: // Names with offsets table.
+		return idx.indexedNamesEnd(read)
+	}
+
+	// Names of legacy format are prefixed with length.
+	offset := idx.namesOffset
+
+	for i := int64(0); i < idx.namesLen; i++ {
+		nameLen, err := read(offset, 4)
+		if err != nil {
+			return 0, &ValidationError{Section: "names", Index: i, Err: err}
+		}
+
+		offset += 4 + int64(binary.BigEndian.Uint32(nameLen))
+	}
+
+	return offset, nil
+}
+
+func (idx *CIDRIndexFile[S]) indexedNamesEnd(read func(off, n int64) ([]byte, error)) (int64, error) {
+	prev := uint32(0)
+
+	for i := int64(0); i <= idx.namesLen; i++ {
+		offset, err := read(idx.namesOffset+4*i, 4)
+		if err != nil {
+			return 0, &ValidationError{Section: "names", Index: i, Err: fmt.Errorf("read offset: %w", err)}
+		}
+
+		o := binary.BigEndian.Uint32(offset)
+		if o < prev {
+			return 0, invalid("names", i, "offset %d is less than previous %d", o, prev)
+		}
+
+		prev = o
+	}
+
+	return idx.namesDataOffset + int64(prev), nil
+}
+
+func (idx *CIDRIndexFile[S]) frontCodedNamesEnd(r io.Reader, read func(off, n int64) ([]byte, error)) (int64, error) {
+	fi, err := readFrontCodedIndex(int(idx.namesLen), r)
+	if err != nil {
+		return 0, &ValidationError{Section: "names", Index: -1, Err: err}
+	}
+
+	for bucket := range len(fi.offsets) - 1 {
+		data, err := read(idx.namesDataOffset+int64(fi.offsets[bucket]), int64(fi.offsets[bucket+1]-fi.offsets[bucket]))
+		if err != nil {
+			return 0, invalid("names", int64(bucket*fi.bucketSize), "read bucket %d: %w", bucket, err)
+		}
+
+		if err := decodeBucket(data, fi.bucketLen(bucket, int(idx.namesLen)), func(int, []byte) bool {
+			return true
+		}); err != nil {
+			return 0, invalid("names", int64(bucket*fi.bucketSize), "bucket %d: %w", bucket, err)
+		}
+	}
+
+	return idx.namesDataOffset + fi.size(), nil
+}
+
+// readFull reads n bytes, allocating memory as data is read, so that invalid length does not cause a huge allocation.
+func readFull(r io.Reader, n int64) ([]byte, error) {
+	const chunk = 1 << 16
+
+	if n < 0 {
+		return nil, fmt.Errorf("invalid length: %d", n)
+	}
+
+	b := make([]byte, 0, min(n, chunk))
+
+	for int64(len(b)) < n {
+		l := len(b)
+
+		// Buffer is at most doubled beyond data that was read.
+		b = append(b, make([]byte, min(n-int64(l), max(int64(l), chunk)))...)
+
+		if _, err := io.ReadFull(r, b[l:]); err != nil {
+			return nil, err
+		}
+	}
+
+	return b, nil
+}
@@ -0,0 +1,325 @@
+package netrie_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vearutop/netrie"
+)
+
+func TestValidate(t *testing.T) {
+	f, err := os.Open("testdata/cities.bin")
+	require.NoError(t, err)
+
+	defer f.Close()
+
+	require.NoError(t, netrie.Validate(f))
+
+	idx := loadCities(t)
+
+	for _, tc := range []struct {
+		name string
+		opt  func(o *netrie.SaveOptions)
+	}{
+		{"none", func(o *netrie.SaveOptions) { o.Compression = netrie.CompressionNone }},
+		{"front_coded", func(o *netrie.SaveOptions) {
+			o.Compression = netrie.CompressionNone
+			o.FrontCodedNames = true
+		}},
+		{"zstd", func(o *netrie.SaveOptions) {}},
+		{"zstd_blocks", func(o *netrie.SaveOptions) { o.BlockSize = 1024 }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			buf := bytes.NewBuffer(nil)
+			require.NoError(t, idx.SaveCompressed(buf, tc.opt))
+
+			require.NoError(t, netrie.Validate(bytes.NewReader(buf.Bytes())))
+
+			if tc.name == "zstd" {
+				return
+			}
+
+			// Trailing data.
+			err := netrie.Validate(bytes.NewReader(append(buf.Bytes(), 0)))
+			require.ErrorIs(t, err, netrie.ErrInvalidIndex)
+		})
+	}
+
+	idx.Minimize()
+
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, idx.Save(buf))
+	require.NoError(t, netrie.Validate(bytes.NewReader(buf.Bytes())))
+}
+
+func TestValidate_corrupted(t *testing.T) {
+	idx := netrie.NewCIDRIndex()
+	require.NoError(t, idx.AddCIDR("10.0.0.0/8", "foo"))
+	require.NoError(t, idx.AddCIDR("10.1.0.0/16", "bar"))
+
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, idx.Save(buf))
+
+	data := buf.Bytes()
+	nodesOffset := 20 + int(binary.BigEndian.Uint32(data[16:20]))
+	lastNode := nodesOffset + (idx.LenNodes()-1)*11
+
+	for _, tc := range []struct {
+		name    string
+		corrupt func(b []byte) []byte
+		section string
+		index   int64
+		err     string
+	}{
+		{
+			name: "child_out_of_range",
+			corrupt: func(b []byte) []byte {
+				binary.BigEndian.PutUint32(b[nodesOffset:], 1000)
+
+				return b
+			},
+			section: "nodes", index: 0,
+			err: "invalid index: nodes[0]: child 1000 is out of range [0, 17)",
+		},
+		{
+			name: "cycle",
+			corrupt: func(b []byte) []byte {
+				binary.BigEndian.PutUint32(b[nodesOffset:], 0)
+
+				return b
+			},
+			section: "nodes", index: 0,
+			err: "invalid index: nodes[0]: path is longer than 128 bits, nodes may form a cycle",
+		},
+		{
+			name: "name_id",
+			corrupt: func(b []byte) []byte {
+				binary.BigEndian.PutUint16(b[lastNode+8:], 3)
+
+				return b
+			},
+			section: "nodes", index: 16,
+			err: "invalid index: nodes[16]: name id 3 is out of range [1, 2]",
+		},
+		{
+			name: "mask_len",
+			corrupt: func(b []byte) []byte {
+				b[lastNode+10] = 24
+
+				return b
+			},
+			section: "nodes", index: 16,
+			err: "invalid index: nodes[16]: mask length 24 does not match depth 16",
+		},
+		{
+			name: "truncated",
+			corrupt: func(b []byte) []byte {
+				return b[:len(b)-1]
+			},
+			section: "names", index: -1,
+		},
+		{
+			name: "nodes_len",
+			corrupt: func(b []byte) []byte {
+				binary.BigEndian.PutUint32(b[8:12], 1<<30)
+
+				return b
+			},
+			section: "nodes", index: -1,
+		},
+		{
+			name: "header",
+			corrupt: func(b []byte) []byte {
+				b[3] = 7
+
+				return b
+			},
+			section: "header", index: -1,
+			err: "invalid index: header: invalid version: 7",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			b := tc.corrupt(bytes.Clone(data))
+
+			err := netrie.Validate(bytes.NewReader(b))
+			require.ErrorIs(t, err, netrie.ErrInvalidIndex)
+
+			var ve *netrie.ValidationError
+			require.True(t, errors.As(err, &ve))
+			assert.Equal(t, tc.section, ve.Section)
+			assert.Equal(t, tc.index, ve.Index)
+
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			}
+
+			if tc.section == "nodes" && tc.index >= 0 {
+				_, err = netrie.Load(bytes.NewReader(b), func(o *netrie.Options) { o.Strict = true })
+				assert.EqualError(t, err, tc.err)
+			}
+		})
+	}
+
+	// Non-strict open trusts data.
+	binary.BigEndian.PutUint32(data[nodesOffset:], 1000)
+
+	l, err := netrie.Open(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Contains(t, l.Lookup("10.1.2.3"), "error: ")
+}
+
+func TestLoad_limits(t *testing.T) {
+	idx := netrie.NewCIDRIndex()
+	idx.Metadata().Name = "test"
+	require.NoError(t, idx.AddCIDR("10.0.0.0/8", "foo"))
+	require.NoError(t, idx.AddCIDR("10.1.0.0/16", strings.Repeat("b", 100)))
+
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, idx.SaveCompressed(buf, func(o *netrie.SaveOptions) {
+		o.Compression = netrie.CompressionNone
+		o.NamesOffsets = true
+	}))
+
+	data := buf.Bytes()
+
+	for name, opt := range map[string]func(o *netrie.Options){
+		"nodes":    func(o *netrie.Options) { o.MaxNodes = 10 },
+		"names":    func(o *netrie.Options) { o.MaxNames = 1 },
+		"metadata": func(o *netrie.Options) { o.MaxMetadataLen = 10 },
+		"name_len": func(o *netrie.Options) { o.MaxNameLen = 10 },
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := netrie.Load(bytes.NewReader(data), opt)
+			require.ErrorIs(t, err, netrie.ErrLimitExceeded)
+
+			l, err := netrie.Open(bytes.NewReader(data), opt)
+			if name != "name_len" {
+				require.ErrorIs(t, err, netrie.ErrLimitExceeded)
+
+				return
+			}
+
+			// Names are read on demand.
+			require.NoError(t, err)
+			assert.Equal(t, "foo", l.Lookup("10.2.3.4"))
+
+			_, err = l.SafeLookupIP(net.ParseIP("10.1.2.3"))
+			require.ErrorIs(t, err, netrie.ErrLimitExceeded)
+		})
+	}
+
+	// Huge number of nodes in header does not allocate memory before nodes are read.
+	b := bytes.Clone(data)
+	binary.BigEndian.PutUint32(b[8:12], 1<<30)
+
+	var before, after runtime.MemStats
+
+	runtime.ReadMemStats(&before)
+
+	_, err := netrie.Load(bytes.NewReader(b))
+	require.Error(t, err)
+
+	runtime.ReadMemStats(&after)
+	assert.Less(t, after.TotalAlloc-before.TotalAlloc, uint64(10<<20))
+
+	// Child out of range is rejected without strict option, so that lookups do not panic.
+	b = bytes.Clone(data)
+	binary.BigEndian.PutUint32(b[20+int(binary.BigEndian.Uint32(b[16:20])):], 1000)
+
+	_, err = netrie.Load(bytes.NewReader(b))
+	require.ErrorIs(t, err, netrie.ErrInvalidIndex)
+}
+
+// reblock re-compresses payload of seekable zstd index data with a short first block.
+func reblock(t *testing.T, data []byte, first int) []byte {
+	t.Helper()
+
+	base := 20 + int(binary.BigEndian.Uint32(data[16:20]))
+	blockSize := int(binary.BigEndian.Uint32(data[base:]))
+	blocks := int(binary.BigEndian.Uint32(data[base+4:]))
+	blocksOffset := base + 8 + 8*(blocks+1)
+
+	dec, err := zstd.NewReader(nil)
+	require.NoError(t, err)
+
+	defer dec.Close()
+
+	var payload []byte
+
+	for i := 0; i < blocks; i++ {
+		from := blocksOffset + int(binary.BigEndian.Uint64(data[base+8+8*i:]))
+		to := blocksOffset + int(binary.BigEndian.Uint64(data[base+16+8*i:]))
+
+		payload, err = dec.DecodeAll(data[from:to], payload)
+		require.NoError(t, err)
+	}
+
+	enc, err := zstd.NewWriter(nil)
+	require.NoError(t, err)
+
+	var (
+		compressed []byte
+		offsets    = []uint64{0}
+	)
+
+	for len(payload) > 0 {
+		n := min(blockSize, len(payload))
+		if len(offsets) == 1 {
+			n = first
+		}
+
+		compressed = enc.EncodeAll(payload[:n], compressed)
+		offsets = append(offsets, uint64(len(compressed)))
+		payload = payload[n:]
+	}
+
+	res := bytes.Clone(data[:base+4])
+	res = binary.BigEndian.AppendUint32(res, uint32(len(offsets)-1))
+
+	for _, off := range offsets {
+		res = binary.BigEndian.AppendUint64(res, off)
+	}
+
+	return append(res, compressed...)
+}
+
+func TestLoad_shortBlock(t *testing.T) {
+	idx := netrie.NewCIDRLargeIndex()
+	require.NoError(t, idx.AddCIDR("10.0.0.0/8", "foo"))
+	require.NoError(t, idx.AddCIDR("10.1.0.0/16", "bar"))
+	require.NoError(t, idx.AddCIDR("2001:db8::/32", "baz"))
+
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, idx.SaveCompressed(buf, func(o *netrie.SaveOptions) {
+		o.BlockSize = 64
+		o.NamesOffsets = true
+	}))
+
+	// Re-encoded with full blocks.
+	data := reblock(t, buf.Bytes(), 64)
+	require.NoError(t, netrie.Validate(bytes.NewReader(data)))
+
+	// Offsets in payload are mapped to blocks of block size, only the last block can be shorter.
+	data = reblock(t, buf.Bytes(), 50)
+
+	_, err := netrie.Load(bytes.NewReader(data))
+	require.ErrorContains(t, err, "block 0 of 50 bytes, only last block can be smaller than block size 64")
+
+	_, err = netrie.Open(bytes.NewReader(data), func(o *netrie.Options) { o.Strict = true })
+	require.ErrorIs(t, err, netrie.ErrInvalidIndex)
+
+	require.ErrorIs(t, netrie.Validate(bytes.NewReader(data)), netrie.ErrInvalidIndex)
+
+	l, err := netrie.Open(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Contains(t, l.Lookup("10.1.2.3"), "error: ")
+}